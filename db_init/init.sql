@@ -1,12 +1,22 @@
 DROP TABLE IF EXISTS chat_user;
 DROP TABLE IF EXISTS chat;
 DROP TABLE IF EXISTS chat_message;
+DROP TABLE IF EXISTS chat_message_revision;
+DROP TABLE IF EXISTS chat_message_hidden;
+DROP TABLE IF EXISTS message_reaction;
+DROP TABLE IF EXISTS message_mention;
+DROP TABLE IF EXISTS attachment;
 DROP TABLE IF EXISTS chat_member;
+DROP TABLE IF EXISTS chat_invite;
+DROP TABLE IF EXISTS chat_invite_use;
+DROP TABLE IF EXISTS chat_join_request;
+DROP TABLE IF EXISTS session;
 
 CREATE TABLE chat_user (
     id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
     username VARCHAR NOT NULL UNIQUE,
     email VARCHAR NOT NULL,
+    password_hash VARCHAR NOT NULL,
     created_at TIMESTAMP DEFAULT NOW() NOT NULL,
     updated_at TIMESTAMP,
     deleted_at TIMESTAMP,
@@ -14,30 +24,268 @@ CREATE TABLE chat_user (
 );
 
 CREATE TABLE chat (
-    id uuid DEFAULT gen_random_uuid() PRIMARY KEY
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    kind VARCHAR DEFAULT 'group' NOT NULL
+        CHECK (kind IN ('direct', 'group')),
+    -- Ids of both members of a direct chat in order, so that every pair of
+    -- users has a single direct chat
+    direct_key VARCHAR UNIQUE,
+    name VARCHAR DEFAULT '' NOT NULL,
+    topic VARCHAR DEFAULT '' NOT NULL,
+    description VARCHAR DEFAULT '' NOT NULL,
+    -- An image attachment of the chat, see below
+    avatar_attachment_id uuid,
+    created_by uuid,
+    -- Public chats can be found and joined by anyone, request chats can be
+    -- found by anyone but joining needs the approval of an admin
+    visibility VARCHAR DEFAULT 'private' NOT NULL
+        CHECK (visibility IN ('private', 'public', 'request')),
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    CHECK ((kind = 'direct') = (direct_key IS NOT NULL)),
+    CHECK (kind = 'group' OR visibility = 'private'),
+    FOREIGN KEY (created_by) REFERENCES chat_user (id)
+        ON DELETE SET NULL
 );
 
 CREATE TABLE chat_message (
     id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
     user_id uuid NOT NULL,
     chat_id uuid NOT NULL,
+    kind VARCHAR DEFAULT 'text' NOT NULL
+        CHECK (kind IN ('text', 'system')),
     content VARCHAR,
+    metadata JSONB,
     created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    edited_at TIMESTAMP,
+    -- Deleted messages are kept as tombstones without content
+    deleted_at TIMESTAMP,
+    reply_to_id uuid,
+    -- Replies belong to the thread of the message they reply to, roots
+    -- keep track of their replies
+    thread_root_id uuid,
+    thread_reply_count INT DEFAULT 0 NOT NULL,
+    last_reply_at TIMESTAMP,
+    -- Words are not stemmed so that search works the same in any language
+    content_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(content, ''))) STORED,
+    -- Chosen by clients so that retried sends are not saved twice
+    client_id VARCHAR,
+    UNIQUE (chat_id, user_id, client_id),
     FOREIGN KEY (chat_id) REFERENCES chat (id)
         ON DELETE CASCADE
         ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES chat_user (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (reply_to_id) REFERENCES chat_message (id)
+        ON DELETE SET NULL,
+    FOREIGN KEY (thread_root_id) REFERENCES chat_message (id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE message_reaction (
+    message_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    emoji VARCHAR NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (message_id, user_id, emoji),
+    FOREIGN KEY (message_id) REFERENCES chat_message (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
     FOREIGN KEY (user_id) REFERENCES chat_user (id)
         ON DELETE CASCADE
         ON UPDATE CASCADE
 );
 
+-- Offsets are in UTF-16 code units, see chat.Mention
+CREATE TABLE message_mention (
+    message_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    start_offset INT NOT NULL,
+    length INT NOT NULL,
+    PRIMARY KEY (message_id, start_offset),
+    FOREIGN KEY (message_id) REFERENCES chat_message (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES chat_user (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+CREATE INDEX message_mention_user_id_idx ON message_mention (user_id);
+
+-- Uploads are linked to a message once it is sent. Their content is stored
+-- in a blob store under its sha256, so uploads of the same file share it.
+CREATE TABLE attachment (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    message_id uuid,
+    chat_id uuid NOT NULL,
+    uploader_id uuid NOT NULL,
+    filename VARCHAR NOT NULL,
+    content_type VARCHAR NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 VARCHAR NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    -- Filled in by the thumbnail worker for images
+    width INT,
+    height INT,
+    blurhash VARCHAR,
+    thumbnail_sha256 VARCHAR,
+    -- NULL for attachments that get no thumbnail
+    thumbnail_status VARCHAR
+        CHECK (thumbnail_status IN ('pending', 'ready', 'failed')),
+    thumbnail_attempts INT DEFAULT 0 NOT NULL,
+    thumbnail_claimed_at TIMESTAMP,
+    FOREIGN KEY (message_id) REFERENCES chat_message (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (chat_id) REFERENCES chat (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (uploader_id) REFERENCES chat_user (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+CREATE INDEX attachment_message_id_idx ON attachment (message_id);
+CREATE INDEX attachment_thumbnail_pending_idx ON attachment (created_at)
+    WHERE thumbnail_status = 'pending';
+
+ALTER TABLE chat ADD FOREIGN KEY (avatar_attachment_id) REFERENCES attachment (id)
+    ON DELETE SET NULL;
+
 CREATE TABLE chat_member (
     chat_id uuid NOT NULL,
     user_id uuid NOT NULL,
+    role VARCHAR DEFAULT 'member' NOT NULL
+        CHECK (role IN ('owner', 'admin', 'member')),
+    last_read_message_id uuid,
+    last_read_at TIMESTAMP,
+    PRIMARY KEY (chat_id, user_id),
+    FOREIGN KEY (chat_id) REFERENCES chat (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES chat_user (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (last_read_message_id) REFERENCES chat_message (id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX chat_message_chat_id_created_at_idx ON chat_message (chat_id, created_at, id);
+CREATE INDEX chat_message_thread_root_id_idx ON chat_message (thread_root_id, created_at, id);
+CREATE INDEX chat_message_content_tsv_idx ON chat_message USING GIN (content_tsv);
+
+-- Prior versions of edited messages, created_at is when the version was
+-- written
+CREATE TABLE chat_message_revision (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    message_id uuid NOT NULL,
+    content VARCHAR,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (message_id) REFERENCES chat_message (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+CREATE INDEX chat_message_revision_message_id_idx ON chat_message_revision (message_id, created_at);
+
+-- Messages members have deleted for themselves only
+CREATE TABLE chat_message_hidden (
+    message_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (message_id, user_id),
+    FOREIGN KEY (message_id) REFERENCES chat_message (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES chat_user (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+-- A chat has a single owner, ownership can only be transferred
+CREATE UNIQUE INDEX chat_member_owner_idx ON chat_member (chat_id) WHERE role = 'owner';
+
+-- Only a hash of the token is kept, like for refresh tokens
+CREATE TABLE chat_invite (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    chat_id uuid NOT NULL,
+    created_by uuid NOT NULL,
+    token_hash VARCHAR NOT NULL UNIQUE,
+    -- NULL for invites that never expire or can be used any number of times
+    expires_at TIMESTAMP,
+    max_uses INT,
+    use_count INT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    revoked_at TIMESTAMP,
+    revoked_by uuid,
+    FOREIGN KEY (chat_id) REFERENCES chat (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES chat_user (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (revoked_by) REFERENCES chat_user (id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX chat_invite_chat_id_idx ON chat_invite (chat_id, created_at);
+
+-- Every join through an invite, for auditing
+CREATE TABLE chat_invite_use (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    invite_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    used_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    FOREIGN KEY (invite_id) REFERENCES chat_invite (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES chat_user (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+CREATE INDEX chat_invite_use_invite_id_idx ON chat_invite_use (invite_id, used_at);
+
+CREATE INDEX chat_discoverable_idx ON chat (created_at, id) WHERE visibility <> 'private';
+
+CREATE TABLE chat_join_request (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    chat_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    status VARCHAR DEFAULT 'pending' NOT NULL
+        CHECK (status IN ('pending', 'approved', 'rejected')),
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    decided_by uuid,
+    decided_at TIMESTAMP,
     FOREIGN KEY (chat_id) REFERENCES chat (id)
         ON DELETE CASCADE
         ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES chat_user (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    FOREIGN KEY (decided_by) REFERENCES chat_user (id)
+        ON DELETE SET NULL
+);
+
+-- A user has at most one pending request per chat, decided ones are kept
+CREATE UNIQUE INDEX chat_join_request_pending_idx ON chat_join_request (chat_id, user_id) WHERE status = 'pending';
+
+CREATE TABLE session (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    user_id uuid NOT NULL,
+    refresh_token_hash VARCHAR NOT NULL UNIQUE,
+    previous_refresh_token_hash VARCHAR,
+    user_agent VARCHAR DEFAULT '' NOT NULL,
+    ip_address VARCHAR DEFAULT '' NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    last_used_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES chat_user (id)
         ON DELETE CASCADE
         ON UPDATE CASCADE
 );
+
+CREATE INDEX session_user_id_idx ON session (user_id);
+CREATE INDEX session_previous_refresh_token_hash_idx ON session (previous_refresh_token_hash);
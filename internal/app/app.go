@@ -2,9 +2,12 @@ package app
 
 import (
 	"context"
+	"go_chat/internal/auth"
+	"go_chat/internal/blob"
 	"go_chat/internal/chat"
 	"go_chat/internal/config"
 	"go_chat/internal/database"
+	"go_chat/internal/pubsub"
 	"go_chat/internal/user"
 	"log"
 	"time"
@@ -28,28 +31,104 @@ func Run() {
 	}
 	defer pool.Close()
 
+	userRepo := user.NewUserRepository(pool)
+
 	chatRepo := chat.NewChatRepository(pool)
-	chatService := chat.NewChatService(chatRepo)
+	chatHub := chat.NewHub()
+	chatPubSub := pubsub.NewPubSub(pool, cfg.ChatEventChannel)
+	chatRelay := chat.NewRelay(chatRepo, chatHub, chatPubSub)
+	var blobStore blob.BlobStore
+	switch cfg.BlobStore {
+	case "s3":
+		blobStore, err = blob.NewS3Store(blob.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		blobStore = blob.NewLocalStore(cfg.BlobDir)
+	}
+
+	chatService := chat.NewChatService(chatRepo, chatHub, chatRelay, blobStore, cfg.MaxAttachmentSize)
 	chatHandler := chat.NewChatHandler(chatService)
+	thumbnailWorker := chat.NewThumbnailWorker(chatRepo, blobStore)
 
-	userRepo := user.NewUserRepository(pool)
-	userService := user.NewUserService(userRepo)
+	tokenManager := auth.NewTokenManager(cfg.JwtSecret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	userService := user.NewUserService(userRepo, tokenManager)
 	userHandler := user.NewUserHandler(userService)
 
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+
+	go chatPubSub.Listen(listenCtx)
+	go thumbnailWorker.Run(listenCtx)
+
 	gin.SetMode(gin.DebugMode)
 	router := gin.New()
 
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
+	router.POST("/auth/login", userHandler.LoginHandler)
+	router.POST("/auth/refresh", userHandler.RefreshHandler)
 	router.POST("/users", userHandler.CreateUserHandler)
-	router.GET("/users/:user_id", userHandler.GetUserHandler)
-	router.PUT("/users/:user_id", userHandler.UpdateUserHandler)
-	router.DELETE("/users/:user_id", userHandler.DeleteUserHandler)
 
-	router.POST("/chats", chatHandler.CreateChatHandler)
-	router.GET("/chats/:chat_id/messages", chatHandler.GetMessagesHandler)
-	router.POST("/chats/:chat_id/messages", chatHandler.SendMessageHandler)
+	authorized := router.Group("/")
+	authorized.Use(authMiddleware(userService))
+
+	authorized.POST("/auth/logout", userHandler.LogoutHandler)
+
+	authorized.GET("/users/:user_id", userHandler.GetUserHandler)
+	authorized.PUT("/users/:user_id", userHandler.UpdateUserHandler)
+	authorized.DELETE("/users/:user_id", userHandler.DeleteUserHandler)
+	authorized.GET("/users/:user_id/sessions", userHandler.GetSessionsHandler)
+	authorized.DELETE("/users/:user_id/sessions", userHandler.RevokeSessionsHandler)
+	authorized.DELETE("/users/:user_id/sessions/:session_id", userHandler.RevokeSessionHandler)
+	authorized.GET("/users/:user_id/chats", chatHandler.GetUserChatsHandler)
+	authorized.GET("/users/:user_id/mentions", chatHandler.GetUserMentionsHandler)
+	authorized.GET("/search/messages", chatHandler.SearchMessagesHandler)
+
+	authorized.POST("/chats", chatHandler.CreateChatHandler)
+	authorized.GET("/chats/discover", chatHandler.DiscoverChatsHandler)
+	authorized.GET("/chats/:chat_id", chatHandler.GetChatHandler)
+	authorized.PATCH("/chats/:chat_id", chatHandler.UpdateChatHandler)
+	authorized.GET("/chats/:chat_id/messages", chatHandler.GetMessagesHandler)
+	authorized.GET("/chats/:chat_id/messages/search", chatHandler.SearchChatMessagesHandler)
+	authorized.POST("/chats/:chat_id/messages", chatHandler.SendMessageHandler)
+	authorized.PATCH("/chats/:chat_id/messages/:message_id", chatHandler.EditMessageHandler)
+	authorized.GET("/chats/:chat_id/messages/:message_id/thread", chatHandler.GetThreadHandler)
+	authorized.DELETE("/chats/:chat_id/messages/:message_id", chatHandler.DeleteMessageHandler)
+	authorized.GET("/chats/:chat_id/messages/:message_id/revisions", chatHandler.GetMessageRevisionsHandler)
+	authorized.PUT("/chats/:chat_id/messages/:message_id/reactions/:emoji", chatHandler.AddReactionHandler)
+	authorized.DELETE("/chats/:chat_id/messages/:message_id/reactions/:emoji", chatHandler.RemoveReactionHandler)
+	authorized.GET("/chats/:chat_id/messages/:message_id/readers", chatHandler.GetMessageReadersHandler)
+	authorized.POST("/chats/:chat_id/read", chatHandler.MarkReadHandler)
+	authorized.POST("/chats/:chat_id/typing", chatHandler.TypingHandler)
+	authorized.POST("/chats/:chat_id/attachments", chatHandler.UploadAttachmentHandler)
+	authorized.GET("/chats/:chat_id/attachments/:attachment_id", chatHandler.GetAttachmentHandler)
+	authorized.GET("/chats/:chat_id/attachments/:attachment_id/thumbnail", chatHandler.GetAttachmentThumbnailHandler)
+	authorized.GET("/chats/:chat_id/ws", chatHandler.SubscribeHandler)
+	authorized.GET("/chats/:chat_id/events", chatHandler.StreamEventsHandler)
+	authorized.POST("/chats/:chat_id/members", chatHandler.AddMemberHandler)
+	authorized.DELETE("/chats/:chat_id/members/:user_id", chatHandler.RemoveMemberHandler)
+	authorized.PUT("/chats/:chat_id/members/:user_id/role", chatHandler.UpdateMemberRoleHandler)
+	authorized.POST("/chats/:chat_id/leave", chatHandler.LeaveChatHandler)
+	authorized.POST("/chats/:chat_id/transfer", chatHandler.TransferOwnershipHandler)
+	authorized.POST("/chats/:chat_id/join", chatHandler.JoinPublicChatHandler)
+	authorized.GET("/chats/:chat_id/join-requests", chatHandler.GetJoinRequestsHandler)
+	authorized.POST("/chats/:chat_id/join-requests/:request_id/approve", chatHandler.ApproveJoinRequestHandler)
+	authorized.POST("/chats/:chat_id/join-requests/:request_id/reject", chatHandler.RejectJoinRequestHandler)
+	authorized.POST("/chats/:chat_id/invites", chatHandler.CreateInviteHandler)
+	authorized.GET("/chats/:chat_id/invites", chatHandler.GetInvitesHandler)
+	authorized.DELETE("/chats/:chat_id/invites/:invite_id", chatHandler.RevokeInviteHandler)
+	authorized.GET("/chats/:chat_id/invites/:invite_id/uses", chatHandler.GetInviteUsesHandler)
+	authorized.POST("/invites/:token/join", chatHandler.JoinChatHandler)
 
 	router.Run(cfg.Hostname + ":" + cfg.Port)
 }
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"errors"
+	"go_chat/internal/auth"
+	"go_chat/internal/user"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authMiddleware resolves the caller from the access token of the request and
+// rejects the request if there is none. Browsers cannot set headers on
+// WebSocket and EventSource connections, so the token is also accepted as the
+// access_token query parameter.
+func authMiddleware(userService *user.UserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token := accessToken(ctx)
+		if token == "" {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing access token"})
+			return
+		}
+
+		caller, sessionId, err := userService.Authenticate(ctx.Request.Context(), token)
+		if err != nil {
+			slog.Error("[authMiddleware]", "Error", err)
+
+			var tokenErr *auth.InvalidTokenError
+			if errors.As(err, &tokenErr) {
+				ctx.Header("WWW-Authenticate", "Bearer")
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": tokenErr.Error()})
+				return
+			}
+
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+
+		auth.SetUserId(ctx, caller.Id)
+		auth.SetSessionId(ctx, sessionId)
+		ctx.Next()
+	}
+}
+
+func accessToken(ctx *gin.Context) string {
+	header := ctx.GetHeader("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	return ctx.Query("access_token")
+}
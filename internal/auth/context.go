@@ -0,0 +1,28 @@
+package auth
+
+import "github.com/gin-gonic/gin"
+
+const (
+	userIdKey    = "auth_user_id"
+	sessionIdKey = "auth_session_id"
+)
+
+// SetUserId stores the authenticated caller in the request context.
+func SetUserId(ctx *gin.Context, userId string) {
+	ctx.Set(userIdKey, userId)
+}
+
+// UserId returns the authenticated caller, it is empty on routes that are
+// not behind the authentication middleware.
+func UserId(ctx *gin.Context) string {
+	return ctx.GetString(userIdKey)
+}
+
+// SetSessionId stores the session the caller's access token belongs to.
+func SetSessionId(ctx *gin.Context, sessionId string) {
+	ctx.Set(sessionIdKey, sessionId)
+}
+
+func SessionId(ctx *gin.Context) string {
+	return ctx.GetString(sessionIdKey)
+}
@@ -0,0 +1,7 @@
+package auth
+
+type InvalidTokenError struct{}
+
+func (e *InvalidTokenError) Error() string {
+	return "Invalid or expired token"
+}
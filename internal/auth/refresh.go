@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const refreshTokenSize = 32
+
+// NewRefreshToken returns a random refresh token along with the hash that is
+// stored in its place.
+func NewRefreshToken() (string, string, error) {
+	buf := make([]byte, refreshTokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashRefreshToken(token), nil
+}
+
+// Refresh tokens carry enough entropy for a fast hash to be sufficient, which
+// also allows looking them up by hash.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
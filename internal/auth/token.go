@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const issuer = "go_chat"
+
+type Claims struct {
+	SessionId string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// TokenManager issues and verifies the signed access tokens identifying the
+// caller of the API.
+type TokenManager struct {
+	secret          []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+func NewTokenManager(secret string, accessTokenTTL time.Duration, refreshTokenTTL time.Duration) *TokenManager {
+	return &TokenManager{
+		secret:          []byte(secret),
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+// RefreshTokenTTL is how long a session stays alive without being refreshed.
+func (m *TokenManager) RefreshTokenTTL() time.Duration {
+	return m.refreshTokenTTL
+}
+
+func (m *TokenManager) IssueAccessToken(userId string, sessionId string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.accessTokenTTL)
+
+	claims := Claims{
+		SessionId: sessionId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+		slog.Error("[TokenManager-IssueAccessToken]", "Error", err)
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+func (m *TokenManager) ParseAccessToken(tokenString string) (Claims, error) {
+	var claims Claims
+
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		&claims,
+		func(token *jwt.Token) (any, error) {
+			return m.secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Subject == "" || claims.SessionId == "" {
+		return Claims{}, &InvalidTokenError{}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,50 @@
+package auth_test
+
+import (
+	"go_chat/internal/auth"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenManager_ParseAccessToken(t *testing.T) {
+	tokens := auth.NewTokenManager("test_secret", time.Minute, time.Hour)
+
+	t.Run("parse issued token", func(t *testing.T) {
+		token, expiresAt, err := tokens.IssueAccessToken("user", "session")
+		require.NoError(t, err)
+		require.True(t, expiresAt.After(time.Now()))
+
+		claims, err := tokens.ParseAccessToken(token)
+		require.NoError(t, err)
+		require.Equal(t, claims.Subject, "user")
+		require.Equal(t, claims.SessionId, "session")
+	})
+
+	t.Run("reject token signed with another secret", func(t *testing.T) {
+		otherTokens := auth.NewTokenManager("other_secret", time.Minute, time.Hour)
+		token, _, err := otherTokens.IssueAccessToken("user", "session")
+		require.NoError(t, err)
+
+		_, err = tokens.ParseAccessToken(token)
+		require.Error(t, err)
+		require.ErrorIs(t, err, &auth.InvalidTokenError{})
+	})
+
+	t.Run("reject expired token", func(t *testing.T) {
+		expiredTokens := auth.NewTokenManager("test_secret", -time.Minute, time.Hour)
+		token, _, err := expiredTokens.IssueAccessToken("user", "session")
+		require.NoError(t, err)
+
+		_, err = tokens.ParseAccessToken(token)
+		require.Error(t, err)
+		require.ErrorIs(t, err, &auth.InvalidTokenError{})
+	})
+
+	t.Run("reject malformed token", func(t *testing.T) {
+		_, err := tokens.ParseAccessToken("not-a-token")
+		require.Error(t, err)
+		require.ErrorIs(t, err, &auth.InvalidTokenError{})
+	})
+}
@@ -0,0 +1,13 @@
+package blob
+
+type BlobDoesNotExistError struct{}
+
+func (e *BlobDoesNotExistError) Error() string {
+	return "Blob does not exist"
+}
+
+type InvalidKeyError struct{}
+
+func (e *InvalidKeyError) Error() string {
+	return "Blob keys must be lowercase letters, digits, dashes and underscores"
+}
@@ -0,0 +1,116 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps blobs as files under a root directory, spread over
+// subdirectories named after the first characters of their keys.
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{
+		root: root,
+	}
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, key[:2], key)
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		slog.Error("[LocalStore-Put]", "Error", err)
+		return err
+	}
+
+	// Writing to a temporary file first keeps readers from seeing partial
+	// blobs, the rename is atomic.
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		slog.Error("[LocalStore-Put]", "Error", err)
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		slog.Error("[LocalStore-Put]", "Error", err)
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		slog.Error("[LocalStore-Put]", "Error", err)
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		slog.Error("[LocalStore-Put]", "Error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, &BlobDoesNotExistError{}
+		}
+
+		slog.Error("[LocalStore-Get]", "Error", err)
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (s *LocalStore) Exists(ctx context.Context, key string) (bool, error) {
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	_, err := os.Stat(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		slog.Error("[LocalStore-Exists]", "Error", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Error("[LocalStore-Delete]", "Error", err)
+		return err
+	}
+
+	return nil
+}
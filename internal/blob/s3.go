@@ -0,0 +1,124 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// S3Store keeps blobs as objects of a bucket on any S3 compatible service.
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+		// Virtual host style addressing needs DNS that self hosted
+		// services usually lack
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		slog.Error("[S3Store-NewS3Store]", "Error", err)
+		return nil, err
+	}
+
+	return &S3Store{
+		client: client,
+		bucket: cfg.Bucket,
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucket, key, content, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		slog.Error("[S3Store-Put]", "Error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	// GetObject is lazy, Stat makes missing objects fail here rather than
+	// on the first read.
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err == nil {
+		_, err = object.Stat()
+	}
+	if err != nil {
+		if object != nil {
+			object.Close()
+		}
+
+		if isNotFound(err) {
+			return nil, &BlobDoesNotExistError{}
+		}
+
+		slog.Error("[S3Store-Get]", "Error", err)
+		return nil, err
+	}
+
+	return object, nil
+}
+
+func (s *S3Store) Exists(ctx context.Context, key string) (bool, error) {
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	_, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+
+		slog.Error("[S3Store-Exists]", "Error", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		slog.Error("[S3Store-Delete]", "Error", err)
+		return err
+	}
+
+	return nil
+}
+
+func isNotFound(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
@@ -0,0 +1,77 @@
+package blob_test
+
+import (
+	"context"
+	"go_chat/internal/blob"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	testAccessKey = "testaccesskey"
+	testSecretKey = "testsecretkey"
+	testBucket    = "test-bucket"
+)
+
+// SetupTestS3 starts MinIO as a stand-in for S3 and returns its endpoint.
+func SetupTestS3(ctx context.Context) (testcontainers.Container, string, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        "minio/minio",
+		ExposedPorts: []string{"9000/tcp"},
+		Env: map[string]string{
+			"MINIO_ROOT_USER":     testAccessKey,
+			"MINIO_ROOT_PASSWORD": testSecretKey,
+		},
+		Cmd:        []string{"server", "/data"},
+		WaitingFor: wait.ForHTTP("/minio/health/live").WithPort("9000/tcp"),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	endpoint, err := container.PortEndpoint(ctx, "9000/tcp", "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewStaticV4(testAccessKey, testSecretKey, ""),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = client.MakeBucket(ctx, testBucket, minio.MakeBucketOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return container, endpoint, nil
+}
+
+func TestS3Store(t *testing.T) {
+	ctx := context.Background()
+	container, endpoint, err := SetupTestS3(ctx)
+	require.NoError(t, err)
+	defer container.Terminate(ctx)
+
+	store, err := blob.NewS3Store(blob.S3Config{
+		Endpoint:  endpoint,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+	})
+	require.NoError(t, err)
+
+	testBlobStore(t, store)
+}
@@ -0,0 +1,29 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"regexp"
+)
+
+// BlobStore keeps immutable blobs of bytes under a key. Keys are chosen by
+// callers, content hashes in practice, so storing the same key twice is
+// expected to store the same bytes.
+type BlobStore interface {
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	// Get returns BlobDoesNotExistError if nothing is stored under the key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{2,127}$`)
+
+// validateKey keeps keys usable as file names and object names alike.
+func validateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return &InvalidKeyError{}
+	}
+
+	return nil
+}
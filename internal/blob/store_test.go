@@ -0,0 +1,66 @@
+package blob_test
+
+import (
+	"context"
+	"go_chat/internal/blob"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testBlobStore checks the behaviour every BlobStore must have.
+func testBlobStore(t *testing.T, store blob.BlobStore) {
+	ctx := context.Background()
+	key := "0f343b0931126a20f133d67c2b018a3b"
+	content := "This is a test blob"
+
+	t.Run("put and get blob", func(t *testing.T) {
+		err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain")
+		require.NoError(t, err)
+
+		exists, err := store.Exists(ctx, key)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		reader, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		stored, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, string(stored), content)
+	})
+
+	t.Run("get blob that does not exist", func(t *testing.T) {
+		_, err := store.Get(ctx, "does-not-exist")
+		require.ErrorIs(t, err, &blob.BlobDoesNotExistError{})
+
+		exists, err := store.Exists(ctx, "does-not-exist")
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("reject invalid key", func(t *testing.T) {
+		err := store.Put(ctx, "../escape", strings.NewReader(content), int64(len(content)), "text/plain")
+		require.ErrorIs(t, err, &blob.InvalidKeyError{})
+	})
+
+	t.Run("delete blob", func(t *testing.T) {
+		err := store.Delete(ctx, key)
+		require.NoError(t, err)
+
+		exists, err := store.Exists(ctx, key)
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		// Deleting twice is not an error
+		err = store.Delete(ctx, key)
+		require.NoError(t, err)
+	})
+}
+
+func TestLocalStore(t *testing.T) {
+	testBlobStore(t, blob.NewLocalStore(t.TempDir()))
+}
@@ -0,0 +1,132 @@
+// Package blurhash encodes images as BlurHash strings, compact placeholders
+// clients can decode into a blurred preview while the image loads. See
+// https://github.com/woltapp/blurhash for the format.
+package blurhash
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+const characters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Encode returns the BlurHash of the image with xComponents by yComponents
+// components, each between 1 and 9. Every pixel is visited once per
+// component, so images should be scaled down beforehand.
+func Encode(img image.Image, xComponents int, yComponents int) string {
+	xComponents = min(max(xComponents, 1), 9)
+	yComponents = min(max(yComponents, 1), 9)
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Linear RGB of every pixel, computed once for all components
+	pixels := make([][3]float64, width*height)
+	for y := range height {
+		for x := range width {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			pixels[y*width+x] = [3]float64{
+				srgbToLinear(int(r >> 8)),
+				srgbToLinear(int(g >> 8)),
+				srgbToLinear(int(b >> 8)),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := range yComponents {
+		for i := range xComponents {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+
+			var factor [3]float64
+			for y := range height {
+				for x := range width {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					pixel := pixels[y*width+x]
+					factor[0] += basis * pixel[0]
+					factor[1] += basis * pixel[1]
+					factor[2] += basis * pixel[2]
+				}
+			}
+
+			scale := 1 / float64(width*height)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, factor := range ac {
+			actualMax = max(actualMax, math.Abs(factor[0]), math.Abs(factor[1]), math.Abs(factor[2]))
+		}
+
+		quantisedMax := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+		hash.WriteString(encode83(quantisedMax, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(encodeDC(dc), 4))
+	for _, factor := range ac {
+		hash.WriteString(encode83(encodeAC(factor, maxValue), 2))
+	}
+
+	return hash.String()
+}
+
+func encodeDC(value [3]float64) int {
+	return linearToSrgb(value[0])<<16 + linearToSrgb(value[1])<<8 + linearToSrgb(value[2])
+}
+
+func encodeAC(value [3]float64, maxValue float64) int {
+	quantise := func(v float64) int {
+		return int(max(0, min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+	}
+
+	return quantise(value[0])*19*19 + quantise(value[1])*19 + quantise(value[2])
+}
+
+func encode83(value int, length int) string {
+	result := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		result[i] = characters[value%83]
+		value /= 83
+	}
+
+	return string(result)
+}
+
+func srgbToLinear(value int) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(value float64) int {
+	v := max(0, min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value float64, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
@@ -0,0 +1,40 @@
+package blurhash_test
+
+import (
+	"go_chat/internal/blurhash"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	t.Run("encode solid image", func(t *testing.T) {
+		solid := image.NewRGBA(image.Rect(0, 0, 8, 6))
+		for i := range solid.Pix {
+			solid.Pix[i] = 0xff
+		}
+
+		hash := blurhash.Encode(solid, 4, 3)
+		require.Len(t, hash, 4+2*4*3)
+		// Size flag for 4x3 components and white as the average colour
+		require.Equal(t, "L", hash[:1])
+		require.Equal(t, "TSUA", hash[2:6])
+
+		require.Equal(t, "00TSUA", blurhash.Encode(solid, 1, 1))
+	})
+
+	t.Run("encode gradient", func(t *testing.T) {
+		img := image.NewGray(image.Rect(0, 0, 16, 16))
+		for x := range 16 {
+			for y := range 16 {
+				img.SetGray(x, y, color.Gray{Y: uint8(x * 16)})
+			}
+		}
+
+		hash := blurhash.Encode(img, 4, 3)
+		require.Len(t, hash, 4+2*4*3)
+		require.NotEqual(t, hash, blurhash.Encode(image.NewGray(img.Rect), 4, 3))
+	})
+}
@@ -0,0 +1,78 @@
+package chat
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	maxAttachmentsPerMessage = 10
+	maxFilenameLength        = 255
+)
+
+// Types browsers may display in place, everything else is downloaded so that
+// uploads cannot run scripts on our origin.
+var inlineContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+type Attachment struct {
+	Id string `json:"id"`
+	// Empty until the attachment is sent with a message
+	MessageId   *string          `json:"message_id,omitempty"`
+	ChatId      string           `json:"chat_id"`
+	UploaderId  string           `json:"uploader_id"`
+	Filename    string           `json:"filename"`
+	ContentType string           `json:"content_type"`
+	Size        int64            `json:"size"`
+	Sha256      string           `json:"sha256"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	Url         string           `json:"url"`
+	// Set once the thumbnail worker processed an image
+	Width           *int    `json:"width,omitempty"`
+	Height          *int    `json:"height,omitempty"`
+	Blurhash        *string `json:"blurhash,omitempty"`
+	ThumbnailSha256 *string `json:"-"`
+	ThumbnailUrl    string  `json:"thumbnail_url,omitempty"`
+}
+
+func (a Attachment) IsInline() bool {
+	return inlineContentTypes[a.ContentType]
+}
+
+func attachmentUrl(chatId string, attachmentId string) string {
+	return fmt.Sprintf("/chats/%s/attachments/%s", chatId, attachmentId)
+}
+
+func thumbnailUrl(chatId string, attachmentId string) string {
+	return attachmentUrl(chatId, attachmentId) + "/thumbnail"
+}
+
+// sanitizeFilename keeps the base name of an uploaded file without control
+// characters, falling back to a generic name when nothing is left.
+func sanitizeFilename(filename string) string {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filename)
+
+	if runes := []rune(filename); len(runes) > maxFilenameLength {
+		filename = string(runes[len(runes)-maxFilenameLength:])
+	}
+
+	if filename == "" || filename == "." || filename == "/" {
+		return "file"
+	}
+
+	return filename
+}
@@ -1,6 +1,87 @@
 package chat
 
+import (
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type ChatKind string
+
+const (
+	// Direct chats are between two users, who cannot be changed
+	DirectChat ChatKind = "direct"
+	GroupChat  ChatKind = "group"
+)
+
+type ChatVisibility string
+
+const (
+	PrivateChat ChatVisibility = "private"
+	// Public chats can be found and joined by anyone
+	PublicChat ChatVisibility = "public"
+	// Request chats can be found by anyone, joining them takes the approval
+	// of an admin
+	RequestChat ChatVisibility = "request"
+)
+
+func (v ChatVisibility) IsValid() bool {
+	switch v {
+	case PrivateChat, PublicChat, RequestChat:
+		return true
+	}
+	return false
+}
+
+const (
+	maxChatNameLength        = 100
+	maxChatTopicLength       = 250
+	maxChatDescriptionLength = 2000
+)
+
 type Chat struct {
-	Id      string   `json:"id"`
-	Members []string `json:"members"`
+	Id          string   `json:"id"`
+	Kind        ChatKind `json:"kind"`
+	Name        string   `json:"name"`
+	Topic       string   `json:"topic"`
+	Description string   `json:"description"`
+	// Image attachment of the chat, if any
+	AvatarId   *string          `json:"avatar_id,omitempty"`
+	AvatarUrl  string           `json:"avatar_url,omitempty"`
+	Visibility ChatVisibility   `json:"visibility"`
+	CreatedBy  *string          `json:"created_by,omitempty"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+	Members    []string         `json:"members"`
+}
+
+// ChatInfoUpdate holds changes to the details of a chat, nil fields are left
+// as they are. An empty AvatarId removes the avatar.
+type ChatInfoUpdate struct {
+	Name        *string
+	Topic       *string
+	Description *string
+	AvatarId    *string
+	Visibility  *ChatVisibility
+}
+
+// directChatKey identifies the direct chat of a pair of users the same way
+// whichever of them creates it.
+func directChatKey(userId string, otherUserId string) string {
+	pair := []string{strings.ToLower(userId), strings.ToLower(otherUserId)}
+	slices.Sort(pair)
+	return strings.Join(pair, ":")
+}
+
+// ChatSummary is an entry of a user's inbox.
+type ChatSummary struct {
+	Chat
+	LastMessage    *Message         `json:"last_message"`
+	LastActivityAt pgtype.Timestamp `json:"last_activity_at"`
+	UnreadCount    int              `json:"unread_count"`
+}
+
+type ChatPage struct {
+	Chats      []ChatSummary `json:"chats"`
+	NextCursor string        `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,47 @@
+package chat
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Cursors are opaque to clients so that what they are made of can change
+// without breaking them.
+
+func encodeChatCursor(lastActivityAt pgtype.Timestamp, chatId string) string {
+	raw := lastActivityAt.Time.Format(time.RFC3339Nano) + "|" + chatId
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeChatCursor(cursor string) (pgtype.Timestamp, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pgtype.Timestamp{}, "", &InvalidCursorError{}
+	}
+
+	timestamp, chatId, ok := strings.Cut(string(raw), "|")
+	if !ok || uuid.Validate(chatId) != nil {
+		return pgtype.Timestamp{}, "", &InvalidCursorError{}
+	}
+
+	lastActivityAt, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return pgtype.Timestamp{}, "", &InvalidCursorError{}
+	}
+
+	return pgtype.Timestamp{Time: lastActivityAt, Valid: true}, chatId, nil
+}
+
+// clampLimit returns the page size to use for a requested limit, zero
+// meaning the client did not ask for any.
+func clampLimit(limit int, defaultLimit int, maxLimit int) int {
+	if limit <= 0 {
+		return defaultLimit
+	}
+
+	return min(limit, maxLimit)
+}
@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DiscoveredChat is a chat that is not private as shown to users who may not
+// be members of it.
+type DiscoveredChat struct {
+	Id          string           `json:"id"`
+	Name        string           `json:"name"`
+	Topic       string           `json:"topic"`
+	Description string           `json:"description"`
+	Visibility  ChatVisibility   `json:"visibility"`
+	MemberCount int              `json:"member_count"`
+	IsMember    bool             `json:"is_member"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+}
+
+type DiscoverPage struct {
+	Chats      []DiscoveredChat `json:"chats"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// Making a join request is retried this many times when the pending request
+// it ran into is decided meanwhile
+const maxJoinRequestAttempts = 3
+
+type JoinRequestStatus string
+
+const (
+	PendingJoinRequest  JoinRequestStatus = "pending"
+	ApprovedJoinRequest JoinRequestStatus = "approved"
+	RejectedJoinRequest JoinRequestStatus = "rejected"
+)
+
+// JoinRequest asks the admins of a request chat to let a user in.
+type JoinRequest struct {
+	Id        string            `json:"id"`
+	ChatId    string            `json:"chat_id"`
+	UserId    string            `json:"user_id"`
+	Status    JoinRequestStatus `json:"status"`
+	CreatedAt pgtype.Timestamp  `json:"created_at"`
+	DecidedBy *string           `json:"decided_by,omitempty"`
+	DecidedAt pgtype.Timestamp  `json:"decided_at"`
+}
+
+// JoinResult holds the chat when it was joined right away and the join
+// request otherwise.
+type JoinResult struct {
+	Chat        *Chat        `json:"chat,omitempty"`
+	JoinRequest *JoinRequest `json:"join_request,omitempty"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes a query match itself literally in a LIKE pattern.
+func escapeLike(query string) string {
+	return likeEscaper.Replace(query)
+}
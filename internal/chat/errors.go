@@ -23,3 +23,165 @@ type NoUserIdProvidedError struct{}
 func (e *NoUserIdProvidedError) Error() string {
 	return "No user ID provided for Chat"
 }
+
+type MessageDoesNotExistError struct{}
+
+func (e *MessageDoesNotExistError) Error() string {
+	return "Message does not exist"
+}
+
+type EventCannotBeReloadedError struct{}
+
+func (e *EventCannotBeReloadedError) Error() string {
+	return "Event cannot be reloaded"
+}
+
+type ActionIsForbiddenError struct{}
+
+func (e *ActionIsForbiddenError) Error() string {
+	return "You are not allowed to perform this action"
+}
+
+type InvalidRoleError struct{}
+
+func (e *InvalidRoleError) Error() string {
+	return "Role must be either admin or member"
+}
+
+type UserDoesNotExistError struct{}
+
+func (e *UserDoesNotExistError) Error() string {
+	return "User does not exist"
+}
+
+type UserIsAlreadyAMemberError struct{}
+
+func (e *UserIsAlreadyAMemberError) Error() string {
+	return "User is already a member of this chat"
+}
+
+type InvalidChatKindError struct{}
+
+func (e *InvalidChatKindError) Error() string {
+	return "Chat kind must be direct or group"
+}
+
+type InvalidDirectChatError struct{}
+
+func (e *InvalidDirectChatError) Error() string {
+	return "Direct chats are between the creator and exactly one other user"
+}
+
+type InvalidChatInfoError struct{}
+
+func (e *InvalidChatInfoError) Error() string {
+	return "Chat name, topic or description is too long"
+}
+
+type InvalidAvatarError struct{}
+
+func (e *InvalidAvatarError) Error() string {
+	return "Avatar must be an image attachment of the chat"
+}
+
+type InviteDoesNotExistError struct{}
+
+func (e *InviteDoesNotExistError) Error() string {
+	return "Invite does not exist or is no longer valid"
+}
+
+type InvalidInviteError struct{}
+
+func (e *InvalidInviteError) Error() string {
+	return "Invite expiry and maximum uses must be positive, expiry at most a year"
+}
+
+type InvalidVisibilityError struct{}
+
+func (e *InvalidVisibilityError) Error() string {
+	return "Visibility must be private, public or request, direct chats are always private"
+}
+
+type JoinRequestDoesNotExistError struct{}
+
+func (e *JoinRequestDoesNotExistError) Error() string {
+	return "Join request does not exist or was decided already"
+}
+
+type DirectChatMembersAreFixedError struct{}
+
+func (e *DirectChatMembersAreFixedError) Error() string {
+	return "Members of direct chats cannot change"
+}
+
+type OwnerCannotLeaveError struct{}
+
+func (e *OwnerCannotLeaveError) Error() string {
+	return "Ownership must be transferred before leaving the chat"
+}
+
+type InvalidCursorError struct{}
+
+func (e *InvalidCursorError) Error() string {
+	return "Invalid cursor"
+}
+
+type InvalidDeletionScopeError struct{}
+
+func (e *InvalidDeletionScopeError) Error() string {
+	return "Deletion scope must be either me or everyone"
+}
+
+type InvalidEmojiError struct{}
+
+func (e *InvalidEmojiError) Error() string {
+	return "Reactions must be a single emoji"
+}
+
+type TooManyReactionsError struct{}
+
+func (e *TooManyReactionsError) Error() string {
+	return "Message cannot have more distinct reactions"
+}
+
+type InvalidReplyError struct{}
+
+func (e *InvalidReplyError) Error() string {
+	return "Replies must be to a message of the same chat"
+}
+
+type AttachmentDoesNotExistError struct{}
+
+func (e *AttachmentDoesNotExistError) Error() string {
+	return "Attachment does not exist"
+}
+
+type AttachmentIsTooLargeError struct{}
+
+func (e *AttachmentIsTooLargeError) Error() string {
+	return "Attachment is too large"
+}
+
+type TooManyAttachmentsError struct{}
+
+func (e *TooManyAttachmentsError) Error() string {
+	return "Message has too many attachments"
+}
+
+type InvalidSearchQueryError struct{}
+
+func (e *InvalidSearchQueryError) Error() string {
+	return "Search query is invalid"
+}
+
+type TooManyTypingEventsError struct{}
+
+func (e *TooManyTypingEventsError) Error() string {
+	return "Too many typing events, try again later"
+}
+
+type InvalidClientIdError struct{}
+
+func (e *InvalidClientIdError) Error() string {
+	return "Client message id is too long"
+}
@@ -0,0 +1,128 @@
+package chat
+
+import "time"
+
+type EventType string
+
+const (
+	MessageCreatedEvent EventType = "message.created"
+	MessageEditedEvent  EventType = "message.edited"
+	MessageDeletedEvent EventType = "message.deleted"
+	// Only reaches the member who hid the message
+	MessageHiddenEvent   EventType = "message.hidden"
+	MessageReadEvent     EventType = "message.read"
+	ReactionAddedEvent   EventType = "reaction.added"
+	ReactionRemovedEvent EventType = "reaction.removed"
+	// Closes the subscriptions the removed member has open on the chat
+	MemberRemovedEvent EventType = "member.removed"
+	// Not stored, never reaches the user who is typing
+	TypingEvent EventType = "typing"
+)
+
+// Event is what subscribers of a chat receive, regardless of the transport
+// they are connected with.
+type Event struct {
+	Type   EventType `json:"type"`
+	Id     string    `json:"id,omitempty"`
+	ChatId string    `json:"chat_id"`
+	// The user the event is about, if any
+	UserId  string `json:"user_id,omitempty"`
+	Payload any    `json:"payload"`
+	// Ephemeral events are dropped once they expire instead of being
+	// delivered late
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+func NewMessageCreatedEvent(message Message) Event {
+	return Event{
+		Type:    MessageCreatedEvent,
+		Id:      message.Id,
+		ChatId:  message.ChatId,
+		Payload: message,
+	}
+}
+
+func NewMessageEditedEvent(message Message) Event {
+	return Event{
+		Type:    MessageEditedEvent,
+		Id:      message.Id,
+		ChatId:  message.ChatId,
+		Payload: message,
+	}
+}
+
+func NewMessageDeletedEvent(message Message) Event {
+	return Event{
+		Type:    MessageDeletedEvent,
+		Id:      message.Id,
+		ChatId:  message.ChatId,
+		Payload: message,
+	}
+}
+
+func NewMessageHiddenEvent(chatId string, userId string, messageId string) Event {
+	return Event{
+		Type:   MessageHiddenEvent,
+		Id:     messageId,
+		ChatId: chatId,
+		UserId: userId,
+	}
+}
+
+func NewMessageReadEvent(receipt ReadReceipt) Event {
+	return Event{
+		Type:    MessageReadEvent,
+		ChatId:  receipt.ChatId,
+		UserId:  receipt.UserId,
+		Payload: receipt,
+	}
+}
+
+func NewReactionEvent(eventType EventType, chatId string, reaction Reaction) Event {
+	return Event{
+		Type:    eventType,
+		Id:      reaction.MessageId,
+		ChatId:  chatId,
+		UserId:  reaction.UserId,
+		Payload: reaction,
+	}
+}
+
+func NewMemberRemovedEvent(chatId string, userId string) Event {
+	return Event{
+		Type:   MemberRemovedEvent,
+		ChatId: chatId,
+		UserId: userId,
+	}
+}
+
+func NewTypingEvent(chatId string, userId string, expiresAt time.Time) Event {
+	return Event{
+		Type:   TypingEvent,
+		ChatId: chatId,
+		UserId: userId,
+		Payload: Typing{
+			ChatId:    chatId,
+			UserId:    userId,
+			ExpiresAt: expiresAt,
+		},
+		ExpiresAt: expiresAt,
+	}
+}
+
+// isExpired tells whether an ephemeral event is too old to be delivered.
+func (e Event) isExpired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// isVisibleTo tells whether a subscriber of the chat may receive the event.
+func (e Event) isVisibleTo(userId string) bool {
+	if e.Type == MessageHiddenEvent {
+		return e.UserId == userId
+	}
+	if e.Type == TypingEvent {
+		return e.UserId != userId
+	}
+
+	return true
+}
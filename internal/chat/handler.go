@@ -1,12 +1,18 @@
 package chat
 
 import (
+	"errors"
+	"go_chat/internal/auth"
 	"log/slog"
+	"mime"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Room for the multipart framing around an uploaded file
+const multipartOverhead = 1 << 20
+
 type ChatHandler struct {
 	service *ChatService
 }
@@ -39,11 +45,11 @@ func (h *ChatHandler) CreateChatHandler(ctx *gin.Context) {
 		return
 	}
 
-	chat, err := h.service.CreateChat(ctx.Request.Context(), req)
+	chat, err := h.service.CreateChat(ctx.Request.Context(), auth.UserId(ctx), req)
 
 	if err != nil {
 		slog.Error("[ChatHandler-CreateChatHandler]", "Error", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create chat"})
+		writeError(ctx, err, "Failed to create chat")
 		return
 	}
 
@@ -51,6 +57,54 @@ func (h *ChatHandler) CreateChatHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, chat)
 }
 
+// GET /chats/:chat_id
+func (h *ChatHandler) GetChatHandler(ctx *gin.Context) {
+	var req GetChatRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetChatHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	chat, err := h.service.GetChat(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetChatHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get chat")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, chat)
+}
+
+// PATCH /chats/:chat_id
+func (h *ChatHandler) UpdateChatHandler(ctx *gin.Context) {
+	var req UpdateChatRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-UpdateChatHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		slog.Error("[ChatHandler-UpdateChatHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	chat, err := h.service.UpdateChat(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-UpdateChatHandler]", "Error", err)
+		writeError(ctx, err, "Failed to update chat")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, chat)
+}
+
 // POST /chats/:chat_id/messages
 func (h *ChatHandler) SendMessageHandler(ctx *gin.Context) {
 	var req SendMessageRequest
@@ -68,11 +122,21 @@ func (h *ChatHandler) SendMessageHandler(ctx *gin.Context) {
 		return
 	}
 
+	if key := ctx.GetHeader("Idempotency-Key"); key != "" {
+		if req.ClientId != "" && req.ClientId != key {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key does not match client_id"})
+			return
+		}
+		req.ClientId = key
+	}
+
+	req.UserId = auth.UserId(ctx)
+
 	message, err := h.service.SendMessage(ctx.Request.Context(), req)
 
 	if err != nil {
 		slog.Error("[ChatHandler-SendMessageHandler]", "Error", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		writeError(ctx, err, "Failed to send message")
 		return
 	}
 
@@ -89,13 +153,923 @@ func (h *ChatHandler) GetMessagesHandler(ctx *gin.Context) {
 		return
 	}
 
-	messages, err := h.service.GetMessages(ctx.Request.Context(), req.ChatId, req.MessageCount, req.Offset)
+	if err := ctx.BindQuery(&req); err != nil {
+		slog.Error("[ChatHandler-GetMessagesHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter"})
+		return
+	}
+
+	page, err := h.service.GetMessages(ctx.Request.Context(), auth.UserId(ctx), req)
 
 	if err != nil {
 		slog.Error("[ChatHandler-GetMessagesHandler]", "Error", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
+		writeError(ctx, err, "Failed to get messages")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// GET /chats/:chat_id/messages/:message_id/thread
+func (h *ChatHandler) GetThreadHandler(ctx *gin.Context) {
+	var req GetThreadRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetThreadHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindQuery(&req); err != nil {
+		slog.Error("[ChatHandler-GetThreadHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter"})
+		return
+	}
+
+	page, err := h.service.GetThread(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetThreadHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get thread")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// PATCH /chats/:chat_id/messages/:message_id
+func (h *ChatHandler) EditMessageHandler(ctx *gin.Context) {
+	var req EditMessageRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-EditMessageHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		slog.Error("[ChatHandler-EditMessageHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	message, err := h.service.EditMessage(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-EditMessageHandler]", "Error", err)
+		writeError(ctx, err, "Failed to edit message")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, message)
+}
+
+// DELETE /chats/:chat_id/messages/:message_id
+func (h *ChatHandler) DeleteMessageHandler(ctx *gin.Context) {
+	var req DeleteMessageRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-DeleteMessageHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindQuery(&req); err != nil {
+		slog.Error("[ChatHandler-DeleteMessageHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter"})
+		return
+	}
+
+	err := h.service.DeleteMessage(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-DeleteMessageHandler]", "Error", err)
+		writeError(ctx, err, "Failed to delete message")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// PUT /chats/:chat_id/messages/:message_id/reactions/:emoji
+func (h *ChatHandler) AddReactionHandler(ctx *gin.Context) {
+	var req ReactionRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-AddReactionHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	err := h.service.AddReaction(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-AddReactionHandler]", "Error", err)
+		writeError(ctx, err, "Failed to add reaction")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// DELETE /chats/:chat_id/messages/:message_id/reactions/:emoji
+func (h *ChatHandler) RemoveReactionHandler(ctx *gin.Context) {
+	var req ReactionRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-RemoveReactionHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	err := h.service.RemoveReaction(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-RemoveReactionHandler]", "Error", err)
+		writeError(ctx, err, "Failed to remove reaction")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// GET /chats/:chat_id/messages/:message_id/revisions
+func (h *ChatHandler) GetMessageRevisionsHandler(ctx *gin.Context) {
+	var req GetMessageRevisionsRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetMessageRevisionsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	revisions, err := h.service.GetMessageRevisions(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetMessageRevisionsHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get message revisions")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revisions)
+}
+
+// GET /chats/:chat_id/ws
+func (h *ChatHandler) SubscribeHandler(ctx *gin.Context) {
+	var req SubscribeRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-SubscribeHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	sub, err := h.service.Subscribe(ctx.Request.Context(), auth.UserId(ctx), req.ChatId)
+	if err != nil {
+		slog.Error("[ChatHandler-SubscribeHandler]", "Error", err)
+		writeError(ctx, err, "Failed to subscribe to chat")
+		return
+	}
+	defer h.service.Unsubscribe(sub)
+
+	// The upgrader replies with an HTTP error itself when the handshake fails
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		slog.Error("[ChatHandler-SubscribeHandler]", "Error", err)
+		return
+	}
+	defer conn.Close()
+
+	userId := auth.UserId(ctx)
+	serveWebSocket(conn, sub, func(event ClientEvent) {
+		switch event.Type {
+		case TypingEvent:
+			err := h.service.Typing(ctx.Request.Context(), userId, TypingRequest{ChatId: req.ChatId})
+			if err != nil {
+				slog.Error("[ChatHandler-SubscribeHandler]", "Error", err)
+			}
+		}
+	})
+}
+
+// POST /chats/:chat_id/typing
+func (h *ChatHandler) TypingHandler(ctx *gin.Context) {
+	var req TypingRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-TypingHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	err := h.service.Typing(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-TypingHandler]", "Error", err)
+		writeError(ctx, err, "Failed to send typing event")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// GET /chats/:chat_id/events
+func (h *ChatHandler) StreamEventsHandler(ctx *gin.Context) {
+	var req StreamEventsRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-StreamEventsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindQuery(&req); err != nil {
+		slog.Error("[ChatHandler-StreamEventsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter"})
+		return
+	}
+
+	if err := ctx.BindHeader(&req); err != nil {
+		slog.Error("[ChatHandler-StreamEventsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid header"})
+		return
+	}
+
+	sub, err := h.service.Subscribe(ctx.Request.Context(), auth.UserId(ctx), req.ChatId)
+	if err != nil {
+		slog.Error("[ChatHandler-StreamEventsHandler]", "Error", err)
+		writeError(ctx, err, "Failed to subscribe to chat")
+		return
+	}
+	defer h.service.Unsubscribe(sub)
+
+	lastEventId := req.LastEventId
+	if lastEventId == "" {
+		lastEventId = req.LastEventIdQuery
+	}
+
+	missed, err := h.service.GetMissedMessages(ctx.Request.Context(), auth.UserId(ctx), req.ChatId, lastEventId)
+	if err != nil {
+		slog.Error("[ChatHandler-StreamEventsHandler]", "Error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get missed messages"})
+		return
+	}
+
+	serveEventStream(ctx, sub, missed)
+}
+
+// PUT /chats/:chat_id/members/:user_id/role
+func (h *ChatHandler) UpdateMemberRoleHandler(ctx *gin.Context) {
+	var req UpdateMemberRoleRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-UpdateMemberRoleHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, messages)
+	if err := ctx.BindJSON(&req); err != nil {
+		slog.Error("[ChatHandler-UpdateMemberRoleHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	role, err := h.service.UpdateMemberRole(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-UpdateMemberRoleHandler]", "Error", err)
+		writeError(ctx, err, "Failed to update member role")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"chat_id": req.ChatId, "user_id": req.UserId, "role": role})
+}
+
+// POST /chats/:chat_id/transfer
+func (h *ChatHandler) TransferOwnershipHandler(ctx *gin.Context) {
+	var req TransferOwnershipRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-TransferOwnershipHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		slog.Error("[ChatHandler-TransferOwnershipHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := h.service.TransferOwnership(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-TransferOwnershipHandler]", "Error", err)
+		writeError(ctx, err, "Failed to transfer ownership")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Ownership transferred successfully"})
+}
+
+// POST /chats/:chat_id/members
+func (h *ChatHandler) AddMemberHandler(ctx *gin.Context) {
+	var req AddMemberRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-AddMemberHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		slog.Error("[ChatHandler-AddMemberHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	message, err := h.service.AddMember(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-AddMemberHandler]", "Error", err)
+		writeError(ctx, err, "Failed to add member")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, message)
+}
+
+// DELETE /chats/:chat_id/members/:user_id
+func (h *ChatHandler) RemoveMemberHandler(ctx *gin.Context) {
+	var req RemoveMemberRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-RemoveMemberHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	message, err := h.service.RemoveMember(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-RemoveMemberHandler]", "Error", err)
+		writeError(ctx, err, "Failed to remove member")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, message)
+}
+
+// POST /chats/:chat_id/leave
+func (h *ChatHandler) LeaveChatHandler(ctx *gin.Context) {
+	var req LeaveChatRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-LeaveChatHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	message, err := h.service.LeaveChat(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-LeaveChatHandler]", "Error", err)
+		writeError(ctx, err, "Failed to leave chat")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, message)
+}
+
+// GET /users/:user_id/chats
+func (h *ChatHandler) GetUserChatsHandler(ctx *gin.Context) {
+	var req GetUserChatsRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetUserChatsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindQuery(&req); err != nil {
+		slog.Error("[ChatHandler-GetUserChatsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter"})
+		return
+	}
+
+	page, err := h.service.GetUserChats(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetUserChatsHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get chats")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// POST /chats/:chat_id/read
+func (h *ChatHandler) MarkReadHandler(ctx *gin.Context) {
+	var req MarkReadRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-MarkReadHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		slog.Error("[ChatHandler-MarkReadHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := h.service.MarkRead(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-MarkReadHandler]", "Error", err)
+		writeError(ctx, err, "Failed to mark chat as read")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// GET /chats/:chat_id/messages/:message_id/readers
+func (h *ChatHandler) GetMessageReadersHandler(ctx *gin.Context) {
+	var req GetMessageReadersRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetMessageReadersHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	receipts, err := h.service.GetMessageReaders(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetMessageReadersHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get message readers")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, receipts)
+}
+
+// POST /chats/:chat_id/attachments
+func (h *ChatHandler) UploadAttachmentHandler(ctx *gin.Context) {
+	var req UploadAttachmentRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-UploadAttachmentHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.service.MaxAttachmentSize()+multipartOverhead)
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		slog.Error("[ChatHandler-UploadAttachmentHandler]", "Error", err)
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(ctx, &AttachmentIsTooLargeError{}, "")
+			return
+		}
+
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		slog.Error("[ChatHandler-UploadAttachmentHandler]", "Error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload attachment"})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.service.UploadAttachment(ctx.Request.Context(), auth.UserId(ctx), req, header.Filename, file, header.Size)
+
+	if err != nil {
+		slog.Error("[ChatHandler-UploadAttachmentHandler]", "Error", err)
+		writeError(ctx, err, "Failed to upload attachment")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, attachment)
+}
+
+// GET /chats/:chat_id/attachments/:attachment_id
+func (h *ChatHandler) GetAttachmentHandler(ctx *gin.Context) {
+	var req GetAttachmentRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetAttachmentHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	attachment, content, err := h.service.GetAttachment(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetAttachmentHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get attachment")
+		return
+	}
+	defer content.Close()
+
+	disposition := "attachment"
+	if attachment.IsInline() {
+		disposition = "inline"
+	}
+
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Header("Cache-Control", "private")
+	ctx.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}),
+	})
+}
+
+// GET /chats/:chat_id/attachments/:attachment_id/thumbnail
+func (h *ChatHandler) GetAttachmentThumbnailHandler(ctx *gin.Context) {
+	var req GetAttachmentRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetAttachmentThumbnailHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	content, err := h.service.GetAttachmentThumbnail(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetAttachmentThumbnailHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get thumbnail")
+		return
+	}
+	defer content.Close()
+
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Header("Cache-Control", "private")
+	ctx.DataFromReader(http.StatusOK, -1, thumbnailContentType, content, map[string]string{
+		"Content-Disposition": "inline",
+	})
+}
+
+// GET /users/:user_id/mentions
+func (h *ChatHandler) GetUserMentionsHandler(ctx *gin.Context) {
+	var req GetUserMentionsRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetUserMentionsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindQuery(&req); err != nil {
+		slog.Error("[ChatHandler-GetUserMentionsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter"})
+		return
+	}
+
+	page, err := h.service.GetUserMentions(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetUserMentionsHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get mentions")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// GET /search/messages
+func (h *ChatHandler) SearchMessagesHandler(ctx *gin.Context) {
+	var req SearchMessagesRequest
+
+	if err := ctx.BindQuery(&req); err != nil {
+		slog.Error("[ChatHandler-SearchMessagesHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter"})
+		return
+	}
+
+	page, err := h.service.SearchMessages(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-SearchMessagesHandler]", "Error", err)
+		writeError(ctx, err, "Failed to search messages")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// GET /chats/:chat_id/messages/search
+func (h *ChatHandler) SearchChatMessagesHandler(ctx *gin.Context) {
+	var req SearchMessagesRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-SearchChatMessagesHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindQuery(&req); err != nil {
+		slog.Error("[ChatHandler-SearchChatMessagesHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter"})
+		return
+	}
+
+	page, err := h.service.SearchChatMessages(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-SearchChatMessagesHandler]", "Error", err)
+		writeError(ctx, err, "Failed to search messages")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// POST /chats/:chat_id/invites
+func (h *ChatHandler) CreateInviteHandler(ctx *gin.Context) {
+	var req CreateInviteRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-CreateInviteHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		slog.Error("[ChatHandler-CreateInviteHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	invite, err := h.service.CreateInvite(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-CreateInviteHandler]", "Error", err)
+		writeError(ctx, err, "Failed to create invite")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, invite)
+}
+
+// GET /chats/:chat_id/invites
+func (h *ChatHandler) GetInvitesHandler(ctx *gin.Context) {
+	var req GetInvitesRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetInvitesHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	invites, err := h.service.GetInvites(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetInvitesHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get invites")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, invites)
+}
+
+// DELETE /chats/:chat_id/invites/:invite_id
+func (h *ChatHandler) RevokeInviteHandler(ctx *gin.Context) {
+	var req RevokeInviteRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-RevokeInviteHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	err := h.service.RevokeInvite(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-RevokeInviteHandler]", "Error", err)
+		writeError(ctx, err, "Failed to revoke invite")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// GET /chats/:chat_id/invites/:invite_id/uses
+func (h *ChatHandler) GetInviteUsesHandler(ctx *gin.Context) {
+	var req GetInviteUsesRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetInviteUsesHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	uses, err := h.service.GetInviteUses(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetInviteUsesHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get invite uses")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, uses)
+}
+
+// POST /invites/:token/join
+func (h *ChatHandler) JoinChatHandler(ctx *gin.Context) {
+	var req JoinChatRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-JoinChatHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	chat, err := h.service.JoinChat(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-JoinChatHandler]", "Error", err)
+		writeError(ctx, err, "Failed to join chat")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, chat)
+}
+
+// GET /chats/discover
+func (h *ChatHandler) DiscoverChatsHandler(ctx *gin.Context) {
+	var req DiscoverChatsRequest
+
+	if err := ctx.BindQuery(&req); err != nil {
+		slog.Error("[ChatHandler-DiscoverChatsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter"})
+		return
+	}
+
+	page, err := h.service.DiscoverChats(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-DiscoverChatsHandler]", "Error", err)
+		writeError(ctx, err, "Failed to discover chats")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// POST /chats/:chat_id/join
+func (h *ChatHandler) JoinPublicChatHandler(ctx *gin.Context) {
+	var req JoinPublicChatRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-JoinPublicChatHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	result, err := h.service.JoinPublicChat(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-JoinPublicChatHandler]", "Error", err)
+		writeError(ctx, err, "Failed to join chat")
+		return
+	}
+
+	// The request still has to be approved
+	if result.JoinRequest != nil {
+		ctx.JSON(http.StatusAccepted, result)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// GET /chats/:chat_id/join-requests
+func (h *ChatHandler) GetJoinRequestsHandler(ctx *gin.Context) {
+	var req GetJoinRequestsRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-GetJoinRequestsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	requests, err := h.service.GetJoinRequests(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-GetJoinRequestsHandler]", "Error", err)
+		writeError(ctx, err, "Failed to get join requests")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, requests)
+}
+
+// POST /chats/:chat_id/join-requests/:request_id/approve
+func (h *ChatHandler) ApproveJoinRequestHandler(ctx *gin.Context) {
+	var req DecideJoinRequestRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-ApproveJoinRequestHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	request, err := h.service.ApproveJoinRequest(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-ApproveJoinRequestHandler]", "Error", err)
+		writeError(ctx, err, "Failed to approve join request")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, request)
+}
+
+// POST /chats/:chat_id/join-requests/:request_id/reject
+func (h *ChatHandler) RejectJoinRequestHandler(ctx *gin.Context) {
+	var req DecideJoinRequestRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[ChatHandler-RejectJoinRequestHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	request, err := h.service.RejectJoinRequest(ctx.Request.Context(), auth.UserId(ctx), req)
+
+	if err != nil {
+		slog.Error("[ChatHandler-RejectJoinRequestHandler]", "Error", err)
+		writeError(ctx, err, "Failed to reject join request")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, request)
+}
+
+// writeError responds with the status matching the error if it is one the
+// client can act upon, and with an internal server error otherwise.
+func writeError(ctx *gin.Context, err error, fallbackMessage string) {
+	var (
+		notMemberErr       *UserIsNotAMemberError
+		forbiddenErr       *ActionIsForbiddenError
+		emptyErr           *MessageContentIsEmptyError
+		invalidRoleErr     *InvalidRoleError
+		messageNotExistErr *MessageDoesNotExistError
+		userNotExistErr    *UserDoesNotExistError
+		alreadyMemberErr   *UserIsAlreadyAMemberError
+		ownerLeaveErr      *OwnerCannotLeaveError
+		cursorErr          *InvalidCursorError
+		deletionScopeErr   *InvalidDeletionScopeError
+		emojiErr           *InvalidEmojiError
+		tooManyReactErr    *TooManyReactionsError
+		replyErr           *InvalidReplyError
+		attachmentErr      *AttachmentDoesNotExistError
+		tooLargeErr        *AttachmentIsTooLargeError
+		tooManyAttachErr   *TooManyAttachmentsError
+		searchQueryErr     *InvalidSearchQueryError
+		clientIdErr        *InvalidClientIdError
+		chatNotExistErr    *ChatDoesNotExistError
+		chatKindErr        *InvalidChatKindError
+		directChatErr      *InvalidDirectChatError
+		fixedMembersErr    *DirectChatMembersAreFixedError
+		chatInfoErr        *InvalidChatInfoError
+		avatarErr          *InvalidAvatarError
+		inviteNotExistErr  *InviteDoesNotExistError
+		inviteErr          *InvalidInviteError
+		visibilityErr      *InvalidVisibilityError
+		joinRequestErr     *JoinRequestDoesNotExistError
+		typingLimitErr     *TooManyTypingEventsError
+	)
+
+	switch {
+	case errors.As(err, &notMemberErr), errors.As(err, &forbiddenErr):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.As(err, &emptyErr), errors.As(err, &invalidRoleErr), errors.As(err, &cursorErr),
+		errors.As(err, &deletionScopeErr), errors.As(err, &emojiErr), errors.As(err, &replyErr),
+		errors.As(err, &tooManyAttachErr), errors.As(err, &searchQueryErr), errors.As(err, &clientIdErr),
+		errors.As(err, &chatKindErr), errors.As(err, &directChatErr), errors.As(err, &chatInfoErr),
+		errors.As(err, &avatarErr), errors.As(err, &inviteErr), errors.As(err, &visibilityErr):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &messageNotExistErr), errors.As(err, &userNotExistErr), errors.As(err, &attachmentErr),
+		errors.As(err, &chatNotExistErr), errors.As(err, &inviteNotExistErr), errors.As(err, &joinRequestErr):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &alreadyMemberErr), errors.As(err, &ownerLeaveErr), errors.As(err, &tooManyReactErr),
+		errors.As(err, &fixedMembersErr):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &tooLargeErr):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.As(err, &typingLimitErr):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fallbackMessage})
+	}
 }
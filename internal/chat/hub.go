@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Buffered so that a burst of messages does not block the broadcaster,
+// subscribers that fall further behind than this are dropped.
+const subscriptionBufferSize = 64
+
+type Subscription struct {
+	ChatId string
+	UserId string
+	events chan Event
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Hub keeps track of the subscribers connected to this instance and fans
+// out chat events to them.
+type Hub struct {
+	mu            sync.RWMutex
+	subscriptions map[string]map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscriptions: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (h *Hub) Subscribe(userId string, chatId string) *Subscription {
+	sub := &Subscription{
+		ChatId: chatId,
+		UserId: userId,
+		events: make(chan Event, subscriptionBufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscriptions[chatId] == nil {
+		h.subscriptions[chatId] = make(map[*Subscription]struct{})
+	}
+	h.subscriptions[chatId][sub] = struct{}{}
+
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(sub)
+}
+
+func (h *Hub) Broadcast(event Event) {
+	// Relayed events may arrive after they stopped being of any use
+	if event.isExpired(time.Now()) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscriptions[event.ChatId] {
+		if !event.isVisibleTo(sub.UserId) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			// The subscriber is not keeping up, closing its channel lets
+			// the connection handler hang up so the client can reconnect.
+			slog.Warn("[Hub-Broadcast]", "Warning", "dropping slow subscriber", "ChatId", sub.ChatId, "UserId", sub.UserId)
+			h.remove(sub)
+		}
+	}
+
+	// Removed members are told why before they are disconnected
+	if event.Type == MemberRemovedEvent {
+		for sub := range h.subscriptions[event.ChatId] {
+			if sub.UserId == event.UserId {
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+// Publish broadcasts the event to the subscribers of this instance only.
+func (h *Hub) Publish(ctx context.Context, event Event) error {
+	h.Broadcast(event)
+	return nil
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription) {
+	subs, ok := h.subscriptions[sub.ChatId]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	close(sub.events)
+
+	if len(subs) == 0 {
+		delete(h.subscriptions, sub.ChatId)
+	}
+}
@@ -0,0 +1,109 @@
+package chat_test
+
+import (
+	"go_chat/internal/chat"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_Broadcast(t *testing.T) {
+	hub := chat.NewHub()
+
+	t.Run("deliver event to subscribers of the chat only", func(t *testing.T) {
+		sub := hub.Subscribe("user", "chat")
+		defer hub.Unsubscribe(sub)
+		otherSub := hub.Subscribe("user", "other_chat")
+		defer hub.Unsubscribe(otherSub)
+
+		message := chat.Message{Id: "message", ChatId: "chat", Content: "hello"}
+		hub.Broadcast(chat.NewMessageCreatedEvent(message))
+
+		event := <-sub.Events()
+		require.Equal(t, event.Type, chat.MessageCreatedEvent)
+		require.Equal(t, event.Id, message.Id)
+		require.Len(t, otherSub.Events(), 0)
+	})
+
+	t.Run("unsubscribe closes the subscription", func(t *testing.T) {
+		sub := hub.Subscribe("user", "chat")
+		hub.Unsubscribe(sub)
+
+		_, ok := <-sub.Events()
+		require.False(t, ok)
+
+		// Unsubscribing twice must not panic
+		hub.Unsubscribe(sub)
+	})
+
+	t.Run("drop subscriber that does not keep up", func(t *testing.T) {
+		sub := hub.Subscribe("user", "slow_chat")
+		defer hub.Unsubscribe(sub)
+
+		message := chat.Message{Id: "message", ChatId: "slow_chat"}
+		for range cap(sub.Events()) + 1 {
+			hub.Broadcast(chat.NewMessageCreatedEvent(message))
+		}
+
+		count := 0
+		for range sub.Events() {
+			count++
+		}
+		require.Equal(t, count, cap(sub.Events()))
+	})
+
+	t.Run("disconnect removed member", func(t *testing.T) {
+		removedSub := hub.Subscribe("removed_user", "chat")
+		defer hub.Unsubscribe(removedSub)
+		sub := hub.Subscribe("user", "chat")
+		defer hub.Unsubscribe(sub)
+
+		hub.Broadcast(chat.NewMemberRemovedEvent("chat", "removed_user"))
+
+		event, ok := <-removedSub.Events()
+		require.True(t, ok)
+		require.Equal(t, event.Type, chat.MemberRemovedEvent)
+		_, ok = <-removedSub.Events()
+		require.False(t, ok)
+
+		event = <-sub.Events()
+		require.Equal(t, event.UserId, "removed_user")
+	})
+	t.Run("deliver hidden message event to its member only", func(t *testing.T) {
+		ownSub := hub.Subscribe("user", "chat")
+		defer hub.Unsubscribe(ownSub)
+		otherSub := hub.Subscribe("other_user", "chat")
+		defer hub.Unsubscribe(otherSub)
+
+		hub.Broadcast(chat.NewMessageHiddenEvent("chat", "user", "message"))
+
+		event := <-ownSub.Events()
+		require.Equal(t, event.Type, chat.MessageHiddenEvent)
+		require.Equal(t, event.Id, "message")
+		require.Len(t, otherSub.Events(), 0)
+	})
+
+	t.Run("deliver typing event to other members only", func(t *testing.T) {
+		ownSub := hub.Subscribe("user", "chat")
+		defer hub.Unsubscribe(ownSub)
+		otherSub := hub.Subscribe("other_user", "chat")
+		defer hub.Unsubscribe(otherSub)
+
+		hub.Broadcast(chat.NewTypingEvent("chat", "user", time.Now().Add(time.Minute)))
+
+		event := <-otherSub.Events()
+		require.Equal(t, event.Type, chat.TypingEvent)
+		require.Equal(t, event.UserId, "user")
+		require.Len(t, ownSub.Events(), 0)
+	})
+
+	t.Run("drop expired typing event", func(t *testing.T) {
+		sub := hub.Subscribe("other_user", "chat")
+		defer hub.Unsubscribe(sub)
+
+		hub.Broadcast(chat.NewTypingEvent("chat", "user", time.Now().Add(-time.Second)))
+
+		require.Len(t, sub.Events(), 0)
+	})
+}
@@ -0,0 +1,68 @@
+package chat
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Mentions past this many in a message are left as plain text
+const maxMentionsPerMessage = 50
+
+// Mention is an @username in the content of a message resolved to a member
+// of the chat. Offset and Length are in UTF-16 code units, which is how
+// JavaScript and most UI toolkits index strings.
+type Mention struct {
+	UserId string `json:"user_id"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// MentionToken is an @username found in the content of a message, before it
+// is resolved. Offset and Length include the @ sign.
+type MentionToken struct {
+	Username string
+	Offset   int
+	Length   int
+}
+
+func isUsernameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
+
+// ParseMentions returns the @username tokens of the content. An @ only starts
+// a mention at the beginning of the content or after a character that cannot
+// be part of a username, so e-mail addresses are not mistaken for mentions.
+func ParseMentions(content string) []MentionToken {
+	var tokens []MentionToken
+
+	runes := []rune(content)
+	offset := 0
+	for i := 0; i < len(runes) && len(tokens) < maxMentionsPerMessage; {
+		r := runes[i]
+		if r != '@' || (i > 0 && isUsernameRune(runes[i-1])) {
+			offset += utf16.RuneLen(r)
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isUsernameRune(runes[end]) {
+			end++
+		}
+
+		// Sentence punctuation right after a mention is not part of it
+		username := strings.TrimRight(string(runes[i+1:end]), ".-")
+		length := 1 + len(utf16.Encode([]rune(username)))
+		if username != "" {
+			tokens = append(tokens, MentionToken{Username: username, Offset: offset, Length: length})
+		}
+
+		for _, r := range runes[i:end] {
+			offset += utf16.RuneLen(r)
+		}
+		i = end
+	}
+
+	return tokens
+}
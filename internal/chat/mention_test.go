@@ -0,0 +1,32 @@
+package chat_test
+
+import (
+	"go_chat/internal/chat"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMentions(t *testing.T) {
+	t.Run("find mentions", func(t *testing.T) {
+		tokens := chat.ParseMentions("@alice can you ask @bob.smith?")
+		require.Equal(t, tokens, []chat.MentionToken{
+			{Username: "alice", Offset: 0, Length: 6},
+			{Username: "bob.smith", Offset: 19, Length: 10},
+		})
+	})
+
+	t.Run("leave out trailing punctuation", func(t *testing.T) {
+		tokens := chat.ParseMentions("thanks @alice.")
+		require.Equal(t, tokens, []chat.MentionToken{{Username: "alice", Offset: 7, Length: 6}})
+	})
+
+	t.Run("count offsets in utf-16 code units", func(t *testing.T) {
+		tokens := chat.ParseMentions("👋 @zoë")
+		require.Equal(t, tokens, []chat.MentionToken{{Username: "zoë", Offset: 3, Length: 4}})
+	})
+
+	t.Run("ignore e-mail addresses and lone @", func(t *testing.T) {
+		require.Empty(t, chat.ParseMentions("mail alice@example.org @ noon"))
+	})
+}
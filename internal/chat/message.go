@@ -2,10 +2,82 @@ package chat
 
 import "github.com/jackc/pgx/v5/pgtype"
 
+type MessageKind string
+
+const (
+	TextMessage MessageKind = "text"
+	// System messages record changes to the chat, their content is the
+	// SystemAction and the user is the one who made the change.
+	SystemMessage MessageKind = "system"
+)
+
+type SystemAction string
+
+const (
+	MemberAddedAction   SystemAction = "member_added"
+	MemberRemovedAction SystemAction = "member_removed"
+	MemberLeftAction    SystemAction = "member_left"
+	MemberJoinedAction  SystemAction = "member_joined"
+	ChatRenamedAction   SystemAction = "chat_renamed"
+	TopicChangedAction  SystemAction = "topic_changed"
+	// The description is not repeated in the metadata as it can be long
+	DescriptionChangedAction SystemAction = "description_changed"
+	AvatarChangedAction      SystemAction = "avatar_changed"
+	VisibilityChangedAction  SystemAction = "visibility_changed"
+)
+
+type DeletionScope string
+
+const (
+	// Hides the message from the member deleting it only
+	DeleteForMe DeletionScope = "me"
+	// Wipes the content of the message for every member
+	DeleteForEveryone DeletionScope = "everyone"
+)
+
 type Message struct {
+	Id        string            `json:"id"`
+	UserId    string            `json:"user_id"`
+	ChatId    string            `json:"chat_id"`
+	Kind      MessageKind       `json:"kind"`
+	Content   string            `json:"content"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt pgtype.Timestamp  `json:"created_at"`
+	EditedAt  pgtype.Timestamp  `json:"edited_at"`
+	DeletedAt pgtype.Timestamp  `json:"deleted_at"`
+	ReplyToId *string           `json:"reply_to_id,omitempty"`
+	// Set on replies, the first message of the thread they are part of
+	ThreadRootId     *string           `json:"thread_root_id,omitempty"`
+	ThreadReplyCount int               `json:"thread_reply_count"`
+	LastReplyAt      pgtype.Timestamp  `json:"last_reply_at"`
+	Reactions        []ReactionSummary `json:"reactions,omitempty"`
+	Mentions         []Mention         `json:"mentions,omitempty"`
+	Attachments      []Attachment      `json:"attachments,omitempty"`
+}
+
+// MessageRevision is a version of a message that has since been edited.
+type MessageRevision struct {
 	Id        string           `json:"id"`
-	UserId    string           `json:"user_id"`
-	ChatId    string           `json:"chat_id"`
+	MessageId string           `json:"message_id"`
 	Content   string           `json:"content"`
 	CreatedAt pgtype.Timestamp `json:"created_at"`
 }
+
+// ReadReceipt is how far a member has read a chat.
+type ReadReceipt struct {
+	UserId            string           `json:"user_id"`
+	ChatId            string           `json:"chat_id"`
+	LastReadMessageId string           `json:"last_read_message_id"`
+	ReadAt            pgtype.Timestamp `json:"read_at"`
+}
+
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// ThreadPage is a page of the replies to Root.
+type ThreadPage struct {
+	Root Message `json:"root"`
+	MessagePage
+}
@@ -0,0 +1,101 @@
+package chat
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+const (
+	// Long enough for ZWJ sequences such as family emoji
+	maxEmojiLength = 64
+	// Distinct emoji a single message can be reacted with
+	maxDistinctReactions = 20
+)
+
+type Emoji string
+
+// IsValid reports whether the emoji is a single emoji, possibly made of
+// several code points joined together, and nothing else.
+func (e Emoji) IsValid() bool {
+	if e == "" || len(e) > maxEmojiLength || !utf8.ValidString(string(e)) {
+		return false
+	}
+
+	cluster, rest, _, _ := uniseg.FirstGraphemeClusterInString(string(e), -1)
+	if rest != "" {
+		return false
+	}
+
+	runes := []rune(cluster)
+	return isKeycap(runes) || isFlag(runes) || isPictograph(runes)
+}
+
+// isKeycap reports whether the runes are a keycap sequence such as 1️⃣.
+func isKeycap(runes []rune) bool {
+	if len(runes) == 3 && runes[1] == '\ufe0f' {
+		runes = []rune{runes[0], runes[2]}
+	}
+
+	if len(runes) != 2 || runes[1] != '\u20e3' {
+		return false
+	}
+
+	r := runes[0]
+	return r >= '0' && r <= '9' || r == '#' || r == '*'
+}
+
+// isFlag reports whether the runes are a pair of regional indicators.
+func isFlag(runes []rune) bool {
+	return len(runes) == 2 &&
+		unicode.Is(unicode.Regional_Indicator, runes[0]) &&
+		unicode.Is(unicode.Regional_Indicator, runes[1])
+}
+
+// isPictograph reports whether the runes are pictographs, optionally joined
+// and modified, that are shown as emoji. Pictographs shown as text by
+// default, such as ©, need the emoji presentation selector.
+func isPictograph(runes []rune) bool {
+	if !unicode.Is(extendedPictographic, runes[0]) {
+		return false
+	}
+
+	presented := unicode.Is(emojiPresentation, runes[0])
+	joined := false
+	for _, r := range runes[1:] {
+		switch {
+		case joined:
+			// Joiners only go between pictographs
+			if !unicode.Is(extendedPictographic, r) {
+				return false
+			}
+			joined = false
+		case r == '\u200d':
+			joined = true
+		case r == '\ufe0f', r >= 0x1f3fb && r <= 0x1f3ff:
+			// Emoji presentation selector and skin tone modifiers
+			presented = true
+		case r >= 0xe0020 && r <= 0xe007f:
+			// Tags of subdivision flags
+		default:
+			return false
+		}
+	}
+
+	return presented && !joined
+}
+
+type Reaction struct {
+	MessageId string `json:"message_id"`
+	UserId    string `json:"user_id"`
+	Emoji     Emoji  `json:"emoji"`
+}
+
+// ReactionSummary aggregates the reactions of a message with one emoji.
+type ReactionSummary struct {
+	Emoji Emoji `json:"emoji"`
+	Count int   `json:"count"`
+	// Whether the user the message was fetched for reacted with it
+	Reacted bool `json:"reacted"`
+}
@@ -0,0 +1,23 @@
+package chat_test
+
+import (
+	"go_chat/internal/chat"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmoji_IsValid(t *testing.T) {
+	t.Run("accept emoji", func(t *testing.T) {
+		for _, emoji := range []string{"👍", "❤️", "👍🏽", "👨‍👩‍👧", "🇹🇷", "1️⃣", "🏴󠁧󠁢󠁳󠁣󠁴󠁿", "©️", "☝🏽", "#⃣"} {
+			require.True(t, chat.Emoji(emoji).IsValid(), emoji)
+		}
+	})
+
+	t.Run("reject anything else", func(t *testing.T) {
+		for _, emoji := range []string{"", "a", "1", "👍 ", "<script>", "‍", string([]byte{0xff}),
+			"👍👎", "👍👍👍", "©", "°", "™", "1👍", "👍‍", "a⃣", "🇹", "🇹🇷🇹🇷", "👍\u0301"} {
+			require.False(t, chat.Emoji(emoji).IsValid(), emoji)
+		}
+	})
+}
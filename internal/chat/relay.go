@@ -0,0 +1,111 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"go_chat/internal/pubsub"
+	"log/slog"
+)
+
+// Publisher hands chat events over to whatever delivers them to the
+// subscribers of the chat.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// relayedEvent is the notification payload. Events too large for a
+// notification are sent without their payload, which the receiving
+// instances load from the database instead.
+type relayedEvent struct {
+	Event
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Relay publishes chat events through Postgres so that subscribers connected
+// to any instance receive them, including the one that published.
+type Relay struct {
+	repo   *ChatRepository
+	hub    *Hub
+	pubsub *pubsub.PubSub
+}
+
+func NewRelay(repo *ChatRepository, hub *Hub, ps *pubsub.PubSub) *Relay {
+	r := &Relay{
+		repo:   repo,
+		hub:    hub,
+		pubsub: ps,
+	}
+	ps.Subscribe(r.receive)
+
+	return r
+}
+
+func (r *Relay) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(relayedEvent{Event: event})
+	if err != nil {
+		slog.Error("[Relay-Publish]", "Error", err)
+		return err
+	}
+
+	if len(payload) > pubsub.MaxPayloadSize {
+		event.Payload = nil
+		payload, err = json.Marshal(relayedEvent{Event: event, Truncated: true})
+		if err != nil {
+			slog.Error("[Relay-Publish]", "Error", err)
+			return err
+		}
+	}
+
+	return r.pubsub.Publish(ctx, payload)
+}
+
+func (r *Relay) receive(payload []byte) {
+	var relayed relayedEvent
+	if err := json.Unmarshal(payload, &relayed); err != nil {
+		slog.Error("[Relay-receive]", "Error", err)
+		return
+	}
+
+	event := relayed.Event
+	if relayed.Truncated {
+		var err error
+		event, err = r.reload(event)
+		if err != nil {
+			slog.Error("[Relay-receive]", "Error", err, "EventType", event.Type, "EventId", event.Id)
+			return
+		}
+	}
+
+	r.hub.Broadcast(event)
+}
+
+// reload restores the payload of a truncated event from the database, with
+// the same details as messages that are read.
+func (r *Relay) reload(event Event) (Event, error) {
+	var newEvent func(Message) Event
+	switch event.Type {
+	case MessageCreatedEvent:
+		newEvent = NewMessageCreatedEvent
+	case MessageEditedEvent:
+		newEvent = NewMessageEditedEvent
+	case MessageDeletedEvent:
+		newEvent = NewMessageDeletedEvent
+	default:
+		return event, &EventCannotBeReloadedError{}
+	}
+
+	ctx := context.Background()
+	message, err := r.repo.GetMessageById(ctx, event.Id)
+	if err != nil {
+		return event, err
+	}
+
+	// Subscribers are told about the message together, so none of its
+	// reactions can be marked as their own
+	messages := []Message{message}
+	if err := r.repo.AttachDetails(ctx, "", messages); err != nil {
+		return event, err
+	}
+
+	return newEvent(messages[0]), nil
+}
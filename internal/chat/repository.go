@@ -5,8 +5,12 @@ import (
 	_ "embed"
 	"errors"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,10 +21,126 @@ var (
 	addChatMemberByIdQuery string
 	//go:embed sql/save_message.sql
 	saveMessageQuery string
+	//go:embed sql/save_reply.sql
+	saveReplyQuery string
+	//go:embed sql/get_message_by_client_id.sql
+	getMessageByClientIdQuery string
+	//go:embed sql/create_direct_chat.sql
+	createDirectChatQuery string
+	//go:embed sql/get_chat_by_direct_key.sql
+	getChatByDirectKeyQuery string
+	//go:embed sql/get_chat_by_id.sql
+	getChatByIdQuery string
+	//go:embed sql/lock_chat.sql
+	lockChatQuery string
+	//go:embed sql/lock_message.sql
+	lockMessageQuery string
+	//go:embed sql/update_chat_info.sql
+	updateChatInfoQuery string
+	//go:embed sql/save_invite.sql
+	saveInviteQuery string
+	//go:embed sql/get_invites_by_chat_id.sql
+	getInvitesByChatIdQuery string
+	//go:embed sql/revoke_invite.sql
+	revokeInviteQuery string
+	//go:embed sql/get_valid_invite_for_update.sql
+	getValidInviteForUpdateQuery string
+	//go:embed sql/use_invite.sql
+	useInviteQuery string
+	//go:embed sql/get_invite_uses.sql
+	getInviteUsesQuery string
+	//go:embed sql/discover_chats.sql
+	discoverChatsQuery string
+	//go:embed sql/save_join_request.sql
+	saveJoinRequestQuery string
+	//go:embed sql/get_pending_join_request.sql
+	getPendingJoinRequestQuery string
+	//go:embed sql/get_join_requests_by_chat_id.sql
+	getJoinRequestsByChatIdQuery string
+	//go:embed sql/decide_join_request.sql
+	decideJoinRequestQuery string
+	//go:embed sql/update_thread_root.sql
+	updateThreadRootQuery string
+	//go:embed sql/get_thread_messages.sql
+	getThreadMessagesQuery string
 	//go:embed sql/get_messages_by_chat_id.sql
 	getMessagesByChatIdQuery string
+	//go:embed sql/get_messages_by_chat_id_after.sql
+	getMessagesByChatIdAfterQuery string
+	//go:embed sql/get_messages_after_id.sql
+	getMessagesAfterIdQuery string
+	//go:embed sql/get_message_by_id.sql
+	getMessageByIdQuery string
+	//go:embed sql/get_member_role.sql
+	getMemberRoleQuery string
+	//go:embed sql/update_member_role.sql
+	updateMemberRoleQuery string
 	//go:embed sql/is_member_of_chat_by_id.sql
 	isMemberOfChatByIdQuery string
+	//go:embed sql/remove_chat_member_by_id.sql
+	removeChatMemberByIdQuery string
+	//go:embed sql/count_chat_members.sql
+	countChatMembersQuery string
+	//go:embed sql/get_chats_by_user_id.sql
+	getChatsByUserIdQuery string
+	//go:embed sql/get_messages_by_ids.sql
+	getMessagesByIdsQuery string
+	//go:embed sql/insert_message_revision.sql
+	insertMessageRevisionQuery string
+	//go:embed sql/edit_message.sql
+	editMessageQuery string
+	//go:embed sql/get_message_revisions.sql
+	getMessageRevisionsQuery string
+	//go:embed sql/delete_message.sql
+	deleteMessageQuery string
+	//go:embed sql/delete_message_revisions.sql
+	deleteMessageRevisionsQuery string
+	//go:embed sql/hide_message.sql
+	hideMessageQuery string
+	//go:embed sql/delete_message_reactions.sql
+	deleteMessageReactionsQuery string
+	//go:embed sql/add_reaction.sql
+	addReactionQuery string
+	//go:embed sql/has_reaction.sql
+	hasReactionQuery string
+	//go:embed sql/remove_reaction.sql
+	removeReactionQuery string
+	//go:embed sql/get_reaction_summaries.sql
+	getReactionSummariesQuery string
+	//go:embed sql/save_mentions.sql
+	saveMentionsQuery string
+	//go:embed sql/resolve_mentions.sql
+	resolveMentionsQuery string
+	//go:embed sql/delete_message_mentions.sql
+	deleteMessageMentionsQuery string
+	//go:embed sql/get_mentions_by_message_ids.sql
+	getMentionsByMessageIdsQuery string
+	//go:embed sql/get_messages_mentioning_user.sql
+	getMessagesMentioningUserQuery string
+	//go:embed sql/save_attachment.sql
+	saveAttachmentQuery string
+	//go:embed sql/get_attachment_by_id.sql
+	getAttachmentByIdQuery string
+	//go:embed sql/get_attachments_by_message_ids.sql
+	getAttachmentsByMessageIdsQuery string
+	//go:embed sql/link_attachments.sql
+	linkAttachmentsQuery string
+	//go:embed sql/delete_message_attachments.sql
+	deleteMessageAttachmentsQuery string
+	//go:embed sql/claim_thumbnail_jobs.sql
+	claimThumbnailJobsQuery string
+	//go:embed sql/complete_thumbnail.sql
+	completeThumbnailQuery string
+	//go:embed sql/fail_thumbnail.sql
+	failThumbnailQuery string
+	//go:embed sql/get_ready_thumbnail.sql
+	getReadyThumbnailQuery string
+	//go:embed sql/search_messages.sql
+	searchMessagesQuery string
+	//go:embed sql/mark_read.sql
+	markReadQuery string
+	//go:embed sql/get_message_readers.sql
+	getMessageReadersQuery string
 )
 
 type ChatRepository struct {
@@ -31,6 +151,51 @@ func NewChatRepository(pool *pgxpool.Pool) *ChatRepository {
 	return &ChatRepository{pool: pool}
 }
 
+// scanMessage scans the columns of a message followed by any extra columns.
+func scanMessage(row pgx.Row, message *Message, extra ...any) error {
+	return row.Scan(append([]any{
+		&message.Id,
+		&message.UserId,
+		&message.ChatId,
+		&message.Kind,
+		&message.Content,
+		&message.Metadata,
+		&message.CreatedAt,
+		&message.EditedAt,
+		&message.DeletedAt,
+		&message.ReplyToId,
+		&message.ThreadRootId,
+		&message.ThreadReplyCount,
+		&message.LastReplyAt,
+	}, extra...)...)
+}
+
+// scanChat scans the columns of a chat followed by any extra columns.
+func scanChat(row pgx.Row, chat *Chat, extra ...any) error {
+	err := row.Scan(append([]any{
+		&chat.Id,
+		&chat.Kind,
+		&chat.Name,
+		&chat.Topic,
+		&chat.Description,
+		&chat.AvatarId,
+		&chat.Visibility,
+		&chat.CreatedBy,
+		&chat.CreatedAt,
+		&chat.Members,
+	}, extra...)...)
+	if err != nil {
+		return err
+	}
+
+	if chat.AvatarId != nil {
+		chat.AvatarUrl = attachmentUrl(chat.Id, *chat.AvatarId)
+	}
+	return nil
+}
+
+// SaveChat creates a chat with the given members, the first one becoming the
+// owner of the chat.
 func (r *ChatRepository) SaveChat(ctx context.Context, userIdList []string) (Chat, error) {
 	if len(userIdList) == 0 {
 		return Chat{}, &NoUserIdProvidedError{}
@@ -51,8 +216,9 @@ func (r *ChatRepository) SaveChat(ctx context.Context, userIdList []string) (Cha
 	}()
 
 	var chatId string
-	err = tx.QueryRow(ctx, createChatQuery).
-		Scan(&chatId)
+	var createdAt pgtype.Timestamp
+	err = tx.QueryRow(ctx, createChatQuery, userIdList[0]).
+		Scan(&chatId, &createdAt)
 	if err != nil {
 		slog.Error("[ChatRepository-SaveChat]", "Error", err)
 		return Chat{}, err
@@ -61,8 +227,13 @@ func (r *ChatRepository) SaveChat(ctx context.Context, userIdList []string) (Cha
 	var insertedUserIdList []string
 	var addedUserId string
 
-	for _, userId := range userIdList {
-		err := tx.QueryRow(ctx, addChatMemberByIdQuery, chatId, userId).
+	for i, userId := range userIdList {
+		role := MemberRole
+		if i == 0 {
+			role = OwnerRole
+		}
+
+		err = tx.QueryRow(ctx, addChatMemberByIdQuery, chatId, userId, role).
 			Scan(&addedUserId)
 		if err != nil {
 			slog.Error("[ChatRepository-SaveChat]", "Error", err)
@@ -73,57 +244,265 @@ func (r *ChatRepository) SaveChat(ctx context.Context, userIdList []string) (Cha
 	}
 
 	return Chat{
-		Id:      chatId,
-		Members: insertedUserIdList,
+		Id:         chatId,
+		Kind:       GroupChat,
+		Visibility: PrivateChat,
+		CreatedBy:  &userIdList[0],
+		CreatedAt:  createdAt,
+		Members:    insertedUserIdList,
 	}, nil
 }
 
+// SaveDirectChat returns the direct chat between the two users, creating it
+// unless it exists already. Neither of them owns the chat.
+func (r *ChatRepository) SaveDirectChat(ctx context.Context, userId string, otherUserId string) (Chat, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-SaveDirectChat]", "Error", err)
+		return Chat{}, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-SaveDirectChat]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	var chatId string
+	key := directChatKey(userId, otherUserId)
+
+	err = tx.QueryRow(ctx, createDirectChatQuery, key, userId).Scan(&chatId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Created before, or concurrently by the other user
+		err = tx.QueryRow(ctx, getChatByDirectKeyQuery, key).Scan(&chatId)
+	} else if err == nil {
+		for _, memberId := range []string{userId, otherUserId} {
+			var addedUserId string
+			err = tx.QueryRow(ctx, addChatMemberByIdQuery, chatId, memberId, MemberRole).
+				Scan(&addedUserId)
+			if errors.Is(err, pgx.ErrNoRows) {
+				err = &UserDoesNotExistError{}
+			}
+			if err != nil {
+				return Chat{}, err
+			}
+		}
+	}
+	if err != nil {
+		return Chat{}, err
+	}
+
+	var chat Chat
+	err = scanChat(tx.QueryRow(ctx, getChatByIdQuery, chatId), &chat)
+	if err != nil {
+		return Chat{}, err
+	}
+
+	return chat, nil
+}
+
+func (r *ChatRepository) GetChatById(ctx context.Context, chatId string) (Chat, error) {
+	var chat Chat
+	err := scanChat(r.pool.QueryRow(ctx, getChatByIdQuery, chatId), &chat)
+	if err != nil {
+		slog.Error("[ChatRepository-GetChatById]", "Error", err)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Chat{}, &ChatDoesNotExistError{}
+		}
+
+		return Chat{}, err
+	}
+
+	return chat, nil
+}
+
 func (r *ChatRepository) SaveMessage(ctx context.Context, userId string, chatId string, content string) (Message, error) {
 	if len(content) == 0 {
 		return Message{}, &MessageContentIsEmptyError{}
 	}
 
-	var message Message
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-SaveMessage]", "Error", err)
+		return Message{}, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-SaveMessage]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
 
-	err := r.pool.QueryRow(ctx, saveMessageQuery, userId, chatId, content).
-		Scan(
-			&message.Id,
-			&message.UserId,
-			&message.ChatId,
-			&message.Content,
-			&message.CreatedAt,
-		)
+	message, _, err := insertMessage(ctx, tx, userId, chatId, "", content, nil)
+	if err != nil {
+		return Message{}, err
+	}
 
+	return message, nil
+}
+
+// SaveReply saves a reply to the parent message in the thread the parent is
+// part of, or in a new one started by the parent.
+func (r *ChatRepository) SaveReply(ctx context.Context, userId string, content string, parent Message) (Message, error) {
+	if len(content) == 0 {
+		return Message{}, &MessageContentIsEmptyError{}
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-SaveReply]", "Error", err)
+		return Message{}, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-SaveReply]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	message, _, err := insertMessage(ctx, tx, userId, parent.ChatId, "", content, &parent)
 	if err != nil {
-		slog.Error("[ChatRepository-SaveMessage]", "Error", err)
 		return Message{}, err
 	}
 
 	return message, nil
 }
 
-func (r *ChatRepository) GetMessages(ctx context.Context, chatId string, messageCount int, offset int) ([]Message, error) {
-	count := messageCount
+// SaveMessageWithAttachments saves a message, a reply if parent is set, along
+// with attachments the user uploaded to the chat and has not sent yet. The
+// content can be empty as long as there are attachments.
+//
+// A message saved earlier by the user in the chat with the same non-empty
+// clientId is returned as it was instead, along with false, so that clients
+// can retry sending safely.
+func (r *ChatRepository) SaveMessageWithAttachments(ctx context.Context, userId string, chatId string, clientId string, content string, parent *Message, attachmentIds []string) (Message, bool, error) {
+	if len(content) == 0 && len(attachmentIds) == 0 {
+		return Message{}, false, &MessageContentIsEmptyError{}
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-SaveMessageWithAttachments]", "Error", err)
+		return Message{}, false, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-SaveMessageWithAttachments]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	message, created, err := insertMessage(ctx, tx, userId, chatId, clientId, content, parent)
+	if err != nil || !created {
+		return message, created, err
+	}
+
+	if len(attachmentIds) == 0 {
+		return message, true, nil
+	}
+
+	rows, err := tx.Query(ctx, linkAttachmentsQuery, message.Id, attachmentIds, chatId, userId)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	for rows.Next() {
+		var attachment Attachment
+		if err = scanAttachment(rows, &attachment); err != nil {
+			rows.Close()
+			return Message{}, false, err
+		}
+		message.Attachments = append(message.Attachments, attachment)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return Message{}, false, err
+	}
+
+	// Attachments of others, of other chats or sent already are not linked
+	if len(message.Attachments) != len(attachmentIds) {
+		err = &AttachmentDoesNotExistError{}
+		return Message{}, false, err
+	}
+
+	return message, true, nil
+}
+
+// insertMessage saves a text message and its mentions within the
+// transaction, as a reply in the thread of parent if it is set. If the user
+// saved a message with the same non-empty clientId in the chat already, that
+// message is returned along with false.
+func insertMessage(ctx context.Context, tx pgx.Tx, userId string, chatId string, clientId string, content string, parent *Message) (Message, bool, error) {
+	var message Message
+	var err error
+	var threadRootId string
+	if parent == nil {
+		err = scanMessage(tx.QueryRow(ctx, saveMessageQuery, userId, chatId, TextMessage, content, nil, clientId), &message)
+	} else {
+		threadRootId = parent.Id
+		if parent.ThreadRootId != nil {
+			threadRootId = *parent.ThreadRootId
+		}
+
+		err = scanMessage(tx.QueryRow(ctx, saveReplyQuery, userId, chatId, TextMessage, content, parent.Id, threadRootId, clientId), &message)
+	}
+
+	// Nothing is inserted when the client id was used already
+	if errors.Is(err, pgx.ErrNoRows) && clientId != "" {
+		err = scanMessage(tx.QueryRow(ctx, getMessageByClientIdQuery, chatId, userId, clientId), &message)
+		return message, false, err
+	}
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	if parent != nil {
+		_, err = tx.Exec(ctx, updateThreadRootQuery, threadRootId, message.CreatedAt)
+		if err != nil {
+			return Message{}, false, err
+		}
+	}
+
+	err = saveMentions(ctx, tx, &message)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	return message, true, nil
+}
+
+// GetMessages returns up to limit messages of the chat, oldest first, leaving
+// out thread replies and the ones userId has hidden. Without after they are
+// the latest ones sent before the before message, or the latest ones overall
+// when before is empty too. With after they are the earliest ones sent after
+// it.
+func (r *ChatRepository) GetMessages(ctx context.Context, userId string, chatId string, before string, after string, limit int) ([]Message, error) {
+	query, anchor := getMessagesByChatIdQuery, before
+	if after != "" {
+		query, anchor = getMessagesByChatIdAfterQuery, after
+	}
 
-	rows, err := r.pool.Query(ctx, getMessagesByChatIdQuery, chatId, count, offset)
+	rows, err := r.pool.Query(ctx, query, chatId, anchor, limit, userId)
 	if err != nil {
 		slog.Error("[ChatRepository-GetMessages]", "Error", err)
 		return nil, err
 	}
 	defer rows.Close()
 
-	var messages []Message
+	messages := []Message{}
 	for rows.Next() {
 		var message Message
-		err := rows.Scan(
-			&message.Id,
-			&message.UserId,
-			&message.ChatId,
-			&message.Content,
-			&message.CreatedAt,
-		)
-
-		if err != nil {
+		if err := scanMessage(rows, &message); err != nil {
 			slog.Error("[ChatRepository-GetMessages]", "Error", err)
 			return nil, err
 		}
@@ -135,6 +514,87 @@ func (r *ChatRepository) GetMessages(ctx context.Context, chatId string, message
 		return nil, err
 	}
 
+	// Walking backwards yields the newest first
+	if after == "" {
+		slices.Reverse(messages)
+	}
+
+	return messages, nil
+}
+
+// GetThreadMessages returns up to limit replies of the thread sent after the
+// after message, or the first ones when it is empty, leaving out the ones
+// userId has hidden.
+func (r *ChatRepository) GetThreadMessages(ctx context.Context, userId string, threadRootId string, after string, limit int) ([]Message, error) {
+	rows, err := r.pool.Query(ctx, getThreadMessagesQuery, threadRootId, after, limit, userId)
+	if err != nil {
+		slog.Error("[ChatRepository-GetThreadMessages]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var message Message
+		if err := scanMessage(rows, &message); err != nil {
+			slog.Error("[ChatRepository-GetThreadMessages]", "Error", err)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetThreadMessages]", "Error", err)
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (r *ChatRepository) GetMessageById(ctx context.Context, messageId string) (Message, error) {
+	var message Message
+
+	err := scanMessage(r.pool.QueryRow(ctx, getMessageByIdQuery, messageId), &message)
+
+	if err != nil {
+		slog.Error("[ChatRepository-GetMessageById]", "Error", err)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Message{}, &MessageDoesNotExistError{}
+		}
+
+		return Message{}, err
+	}
+
+	return message, nil
+}
+
+// GetMessagesAfterId returns up to limit messages of the chat that were
+// sent after the given one, oldest first. Nothing is returned if the
+// message is not part of the chat.
+func (r *ChatRepository) GetMessagesAfterId(ctx context.Context, userId string, chatId string, messageId string, limit int) ([]Message, error) {
+	rows, err := r.pool.Query(ctx, getMessagesAfterIdQuery, chatId, messageId, userId, limit)
+	if err != nil {
+		slog.Error("[ChatRepository-GetMessagesAfterId]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var message Message
+		if err := scanMessage(rows, &message); err != nil {
+			slog.Error("[ChatRepository-GetMessagesAfterId]", "Error", err)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetMessagesAfterId]", "Error", err)
+		return nil, err
+	}
+
 	return messages, nil
 }
 
@@ -159,3 +619,1497 @@ func (r *ChatRepository) IsMemberOfChatById(ctx context.Context, userId string,
 
 	return true, nil
 }
+
+func (r *ChatRepository) GetMemberRole(ctx context.Context, userId string, chatId string) (Role, error) {
+	var role Role
+	err := r.pool.QueryRow(ctx, getMemberRoleQuery, userId, chatId).
+		Scan(&role)
+	if err != nil {
+		slog.Error("[ChatRepository-GetMemberRole]", "Error", err)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", &UserIsNotAMemberError{}
+		}
+
+		return "", err
+	}
+
+	return role, nil
+}
+
+func (r *ChatRepository) UpdateMemberRole(ctx context.Context, chatId string, userId string, role Role) (Role, error) {
+	var updatedRole Role
+	err := r.pool.QueryRow(ctx, updateMemberRoleQuery, chatId, userId, role).
+		Scan(&updatedRole)
+	if err != nil {
+		slog.Error("[ChatRepository-UpdateMemberRole]", "Error", err)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", &UserIsNotAMemberError{}
+		}
+
+		return "", err
+	}
+
+	return updatedRole, nil
+}
+
+// TransferOwnership makes newOwnerId the owner of the chat, the previous owner
+// staying on as an admin.
+func (r *ChatRepository) TransferOwnership(ctx context.Context, chatId string, ownerId string, newOwnerId string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-TransferOwnership]", "Error", err)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-TransferOwnership]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	// The previous owner steps down first, a chat can only have one owner
+	var role Role
+	err = tx.QueryRow(ctx, updateMemberRoleQuery, chatId, ownerId, AdminRole).
+		Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &UserIsNotAMemberError{}
+		}
+
+		return err
+	}
+
+	err = tx.QueryRow(ctx, updateMemberRoleQuery, chatId, newOwnerId, OwnerRole).
+		Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = &UserIsNotAMemberError{}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// UpdateChatInfo applies the changes to the details of the chat, recording
+// each actual change as a system message on behalf of actorId. The updated
+// chat is returned along with the messages.
+func (r *ChatRepository) UpdateChatInfo(ctx context.Context, actorId string, chatId string, update ChatInfoUpdate) (Chat, []Message, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-UpdateChatInfo]", "Error", err)
+		return Chat{}, nil, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-UpdateChatInfo]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	// Concurrent updates would otherwise record changes from stale values
+	_, err = tx.Exec(ctx, lockChatQuery, chatId)
+	if err != nil {
+		return Chat{}, nil, err
+	}
+
+	var chat Chat
+	err = scanChat(tx.QueryRow(ctx, getChatByIdQuery, chatId), &chat)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = &ChatDoesNotExistError{}
+		}
+
+		return Chat{}, nil, err
+	}
+
+	type change struct {
+		action   SystemAction
+		metadata map[string]string
+	}
+	var changes []change
+
+	if update.Name != nil && *update.Name != chat.Name {
+		changes = append(changes, change{ChatRenamedAction, map[string]string{"name": *update.Name, "previous_name": chat.Name}})
+		chat.Name = *update.Name
+	}
+	if update.Topic != nil && *update.Topic != chat.Topic {
+		changes = append(changes, change{TopicChangedAction, map[string]string{"topic": *update.Topic}})
+		chat.Topic = *update.Topic
+	}
+	if update.Description != nil && *update.Description != chat.Description {
+		changes = append(changes, change{DescriptionChangedAction, nil})
+		chat.Description = *update.Description
+	}
+	if update.AvatarId != nil {
+		var avatarId *string
+		if *update.AvatarId != "" {
+			avatarId = update.AvatarId
+		}
+
+		if (avatarId == nil) != (chat.AvatarId == nil) || (avatarId != nil && *avatarId != *chat.AvatarId) {
+			var metadata map[string]string
+			if avatarId != nil {
+				metadata = map[string]string{"avatar_id": *avatarId}
+			}
+			changes = append(changes, change{AvatarChangedAction, metadata})
+
+			chat.AvatarId = avatarId
+			chat.AvatarUrl = ""
+			if avatarId != nil {
+				chat.AvatarUrl = attachmentUrl(chat.Id, *avatarId)
+			}
+		}
+	}
+
+	if update.Visibility != nil && *update.Visibility != chat.Visibility {
+		if chat.Kind == DirectChat {
+			err = &InvalidVisibilityError{}
+			return Chat{}, nil, err
+		}
+
+		changes = append(changes, change{VisibilityChangedAction, map[string]string{"visibility": string(*update.Visibility)}})
+		chat.Visibility = *update.Visibility
+	}
+
+	messages := []Message{}
+	if len(changes) == 0 {
+		return chat, messages, nil
+	}
+
+	_, err = tx.Exec(ctx, updateChatInfoQuery, chatId, chat.Name, chat.Topic, chat.Description, chat.AvatarId, chat.Visibility)
+	if err != nil {
+		return Chat{}, nil, err
+	}
+
+	for _, change := range changes {
+		var message Message
+		err = scanMessage(tx.QueryRow(ctx, saveMessageQuery, actorId, chatId, SystemMessage, change.action, change.metadata, ""), &message)
+		if err != nil {
+			return Chat{}, nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return chat, messages, nil
+}
+
+// AddMember adds the user to the chat as a member and records it as a system
+// message on behalf of actorId, which is returned.
+func (r *ChatRepository) AddMember(ctx context.Context, actorId string, chatId string, userId string) (Message, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-AddMember]", "Error", err)
+		return Message{}, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-AddMember]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	err = addMember(ctx, tx, chatId, userId)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var message Message
+	metadata := map[string]string{"target_user_id": userId}
+	err = scanMessage(tx.QueryRow(ctx, saveMessageQuery, actorId, chatId, SystemMessage, MemberAddedAction, metadata, ""), &message)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return message, nil
+}
+
+// addMember adds the user to the chat as a member within the transaction.
+func addMember(ctx context.Context, tx pgx.Tx, chatId string, userId string) error {
+	var addedUserId string
+	err := tx.QueryRow(ctx, addChatMemberByIdQuery, chatId, userId, MemberRole).
+		Scan(&addedUserId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &UserDoesNotExistError{}
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			// Duplicate key value violates unique constraint
+			if pgErr.Code == "23505" {
+				return &UserIsAlreadyAMemberError{}
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// RemoveMember removes the user from the chat and records it as a system
+// message on behalf of actorId, which is returned. A user removing itself is
+// recorded as having left the chat.
+func (r *ChatRepository) RemoveMember(ctx context.Context, actorId string, chatId string, userId string) (Message, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-RemoveMember]", "Error", err)
+		return Message{}, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-RemoveMember]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	var removedUserId string
+	err = tx.QueryRow(ctx, removeChatMemberByIdQuery, chatId, userId).
+		Scan(&removedUserId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = &UserIsNotAMemberError{}
+		}
+
+		return Message{}, err
+	}
+
+	action := MemberRemovedAction
+	if actorId == removedUserId {
+		action = MemberLeftAction
+	}
+
+	var message Message
+	metadata := map[string]string{"target_user_id": removedUserId}
+	err = scanMessage(tx.QueryRow(ctx, saveMessageQuery, actorId, chatId, SystemMessage, action, metadata, ""), &message)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return message, nil
+}
+
+func (r *ChatRepository) CountMembers(ctx context.Context, chatId string) (int, error) {
+	var count int
+	err := r.pool.QueryRow(ctx, countChatMembersQuery, chatId).
+		Scan(&count)
+	if err != nil {
+		slog.Error("[ChatRepository-CountMembers]", "Error", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetChatsByUserId returns the chats of the user along with their latest
+// message, most recently active first. Only chats that were last active
+// before the given chat are returned when before is valid.
+func (r *ChatRepository) GetChatsByUserId(ctx context.Context, userId string, before pgtype.Timestamp, beforeChatId string, limit int) ([]ChatSummary, error) {
+	var beforeChatIdArg *string
+	if before.Valid {
+		beforeChatIdArg = &beforeChatId
+	}
+
+	rows, err := r.pool.Query(ctx, getChatsByUserIdQuery, userId, before, beforeChatIdArg, limit)
+	if err != nil {
+		slog.Error("[ChatRepository-GetChatsByUserId]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	chats := []ChatSummary{}
+	lastMessageIndex := make(map[string]int)
+	var lastMessageIds []string
+
+	for rows.Next() {
+		var chat ChatSummary
+		var lastMessageId *string
+		err := scanChat(rows, &chat.Chat,
+			&lastMessageId,
+			&chat.LastActivityAt,
+			&chat.UnreadCount,
+		)
+
+		if err != nil {
+			slog.Error("[ChatRepository-GetChatsByUserId]", "Error", err)
+			return nil, err
+		}
+
+		if lastMessageId != nil {
+			lastMessageIndex[*lastMessageId] = len(chats)
+			lastMessageIds = append(lastMessageIds, *lastMessageId)
+		}
+		chats = append(chats, chat)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetChatsByUserId]", "Error", err)
+		return nil, err
+	}
+
+	// Loaded separately so that messages are read the same way everywhere
+	messages, err := r.GetMessagesByIds(ctx, lastMessageIds)
+	if err != nil {
+		slog.Error("[ChatRepository-GetChatsByUserId]", "Error", err)
+		return nil, err
+	}
+
+	if err := r.AttachDetails(ctx, userId, messages); err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		chats[lastMessageIndex[messages[i].Id]].LastMessage = &messages[i]
+	}
+
+	return chats, nil
+}
+
+func (r *ChatRepository) GetMessagesByIds(ctx context.Context, messageIds []string) ([]Message, error) {
+	if len(messageIds) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.pool.Query(ctx, getMessagesByIdsQuery, messageIds)
+	if err != nil {
+		slog.Error("[ChatRepository-GetMessagesByIds]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var message Message
+		if err := scanMessage(rows, &message); err != nil {
+			slog.Error("[ChatRepository-GetMessagesByIds]", "Error", err)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetMessagesByIds]", "Error", err)
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func scanReadReceipt(row pgx.Row, receipt *ReadReceipt) error {
+	return row.Scan(
+		&receipt.UserId,
+		&receipt.ChatId,
+		&receipt.LastReadMessageId,
+		&receipt.ReadAt,
+	)
+}
+
+// MarkRead moves the read cursor of the member to the message. The cursor
+// never moves backwards, false is returned when it is already past the
+// message.
+func (r *ChatRepository) MarkRead(ctx context.Context, userId string, chatId string, messageId string) (ReadReceipt, bool, error) {
+	var receipt ReadReceipt
+	err := scanReadReceipt(r.pool.QueryRow(ctx, markReadQuery, chatId, userId, messageId), &receipt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ReadReceipt{}, false, nil
+		}
+
+		slog.Error("[ChatRepository-MarkRead]", "Error", err)
+		return ReadReceipt{}, false, err
+	}
+
+	return receipt, true, nil
+}
+
+// GetMessageReaders returns the receipts of the members who have read the
+// message, its author excluded.
+func (r *ChatRepository) GetMessageReaders(ctx context.Context, chatId string, messageId string) ([]ReadReceipt, error) {
+	rows, err := r.pool.Query(ctx, getMessageReadersQuery, chatId, messageId)
+	if err != nil {
+		slog.Error("[ChatRepository-GetMessageReaders]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := []ReadReceipt{}
+	for rows.Next() {
+		var receipt ReadReceipt
+		if err := scanReadReceipt(rows, &receipt); err != nil {
+			slog.Error("[ChatRepository-GetMessageReaders]", "Error", err)
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetMessageReaders]", "Error", err)
+		return nil, err
+	}
+
+	return receipts, nil
+}
+
+// EditMessage replaces the content of the message, keeping the current one
+// as a revision. Messages deleted for everyone are reported as not existing.
+func (r *ChatRepository) EditMessage(ctx context.Context, messageId string, content string) (Message, error) {
+	if content == "" {
+		return Message{}, &MessageContentIsEmptyError{}
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-EditMessage]", "Error", err)
+		return Message{}, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-EditMessage]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	// Locks the message, which must not have been deleted for everyone
+	tag, err := tx.Exec(ctx, insertMessageRevisionQuery, messageId)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		err = &MessageDoesNotExistError{}
+		return Message{}, err
+	}
+
+	var message Message
+	err = scanMessage(tx.QueryRow(ctx, editMessageQuery, messageId, content), &message)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = &MessageDoesNotExistError{}
+		}
+
+		return Message{}, err
+	}
+
+	err = saveMentions(ctx, tx, &message)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return message, nil
+}
+
+// GetMessageRevisions returns the prior versions of the message, oldest
+// first.
+func (r *ChatRepository) GetMessageRevisions(ctx context.Context, messageId string) ([]MessageRevision, error) {
+	rows, err := r.pool.Query(ctx, getMessageRevisionsQuery, messageId)
+	if err != nil {
+		slog.Error("[ChatRepository-GetMessageRevisions]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []MessageRevision{}
+	for rows.Next() {
+		var revision MessageRevision
+		err := rows.Scan(&revision.Id, &revision.MessageId, &revision.Content, &revision.CreatedAt)
+		if err != nil {
+			slog.Error("[ChatRepository-GetMessageRevisions]", "Error", err)
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetMessageRevisions]", "Error", err)
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// DeleteMessage wipes the content of the message, its revisions, reactions,
+// mentions and attachments, leaving a tombstone in its place. The blobs of
+// the attachments are left alone as other uploads may share them. False is
+// returned if it was deleted already.
+func (r *ChatRepository) DeleteMessage(ctx context.Context, messageId string) (Message, bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-DeleteMessage]", "Error", err)
+		return Message{}, false, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-DeleteMessage]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	var message Message
+	err = scanMessage(tx.QueryRow(ctx, deleteMessageQuery, messageId), &message)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = nil
+			return Message{}, false, nil
+		}
+
+		return Message{}, false, err
+	}
+
+	_, err = tx.Exec(ctx, deleteMessageRevisionsQuery, messageId)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	_, err = tx.Exec(ctx, deleteMessageReactionsQuery, messageId)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	_, err = tx.Exec(ctx, deleteMessageMentionsQuery, messageId)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	_, err = tx.Exec(ctx, deleteMessageAttachmentsQuery, messageId)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	return message, true, nil
+}
+
+// HideMessage deletes the message for the user only.
+func (r *ChatRepository) HideMessage(ctx context.Context, userId string, messageId string) error {
+	_, err := r.pool.Exec(ctx, hideMessageQuery, messageId, userId)
+	if err != nil {
+		slog.Error("[ChatRepository-HideMessage]", "Error", err)
+		return err
+	}
+
+	return nil
+}
+
+// AddReaction reacts to the message on behalf of the user. False is returned
+// if the user had reacted with the emoji already. Messages deleted for
+// everyone are reported as not existing.
+func (r *ChatRepository) AddReaction(ctx context.Context, reaction Reaction) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-AddReaction]", "Error", err)
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-AddReaction]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	// Concurrent reactions with new emoji could otherwise both pass the
+	// limit on distinct reactions
+	var deletedAt pgtype.Timestamp
+	err = tx.QueryRow(ctx, lockMessageQuery, reaction.MessageId).Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = &MessageDoesNotExistError{}
+		}
+
+		return false, err
+	}
+
+	if deletedAt.Valid {
+		err = &MessageDoesNotExistError{}
+		return false, err
+	}
+
+	tag, err := tx.Exec(ctx, addReactionQuery, reaction.MessageId, reaction.UserId, reaction.Emoji, maxDistinctReactions)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() > 0 {
+		return true, nil
+	}
+
+	// Nothing was inserted, either because the reaction exists or because
+	// the message has as many distinct reactions as it can have
+	var exists bool
+	err = tx.QueryRow(ctx, hasReactionQuery, reaction.MessageId, reaction.UserId, reaction.Emoji).
+		Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	if !exists {
+		err = &TooManyReactionsError{}
+		return false, err
+	}
+
+	return false, nil
+}
+
+// RemoveReaction takes back the reaction of the user. False is returned if
+// there was no such reaction.
+func (r *ChatRepository) RemoveReaction(ctx context.Context, reaction Reaction) (bool, error) {
+	tag, err := r.pool.Exec(ctx, removeReactionQuery, reaction.MessageId, reaction.UserId, reaction.Emoji)
+	if err != nil {
+		slog.Error("[ChatRepository-RemoveReaction]", "Error", err)
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetReactionSummaries returns the reactions of each message, as seen by
+// userId, keyed by message id.
+func (r *ChatRepository) GetReactionSummaries(ctx context.Context, userId string, messageIds []string) (map[string][]ReactionSummary, error) {
+	summaries := make(map[string][]ReactionSummary)
+	if len(messageIds) == 0 {
+		return summaries, nil
+	}
+
+	rows, err := r.pool.Query(ctx, getReactionSummariesQuery, messageIds, userId)
+	if err != nil {
+		slog.Error("[ChatRepository-GetReactionSummaries]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageId string
+		var summary ReactionSummary
+		if err := rows.Scan(&messageId, &summary.Emoji, &summary.Count, &summary.Reacted); err != nil {
+			slog.Error("[ChatRepository-GetReactionSummaries]", "Error", err)
+			return nil, err
+		}
+		summaries[messageId] = append(summaries[messageId], summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetReactionSummaries]", "Error", err)
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// saveMentions resolves the @usernames of the message to members of its chat
+// and replaces its mentions with them within the transaction.
+func saveMentions(ctx context.Context, tx pgx.Tx, message *Message) error {
+	tokens := ParseMentions(message.Content)
+
+	var usernames []string
+	for _, token := range tokens {
+		usernames = append(usernames, token.Username)
+	}
+
+	resolved := make(map[string]string)
+	if len(usernames) > 0 {
+		rows, err := tx.Query(ctx, resolveMentionsQuery, message.ChatId, usernames)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var username, userId string
+			if err := rows.Scan(&username, &userId); err != nil {
+				rows.Close()
+				return err
+			}
+			resolved[username] = userId
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	mentions := []Mention{}
+	for _, token := range tokens {
+		if userId, ok := resolved[token.Username]; ok {
+			mentions = append(mentions, Mention{UserId: userId, Offset: token.Offset, Length: token.Length})
+		}
+	}
+
+	if err := replaceMentions(ctx, tx, message.Id, mentions); err != nil {
+		return err
+	}
+
+	message.Mentions = mentions
+	return nil
+}
+
+func replaceMentions(ctx context.Context, tx pgx.Tx, messageId string, mentions []Mention) error {
+	_, err := tx.Exec(ctx, deleteMessageMentionsQuery, messageId)
+	if err != nil {
+		return err
+	}
+
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	userIds := make([]string, len(mentions))
+	offsets := make([]int, len(mentions))
+	lengths := make([]int, len(mentions))
+	for i, mention := range mentions {
+		userIds[i] = mention.UserId
+		offsets[i] = mention.Offset
+		lengths[i] = mention.Length
+	}
+
+	_, err = tx.Exec(ctx, saveMentionsQuery, messageId, userIds, offsets, lengths)
+	return err
+}
+
+// AttachDetails fills in the reactions of the messages as seen by userId,
+// their mentions and attachments. Without a userId none of the reactions
+// show as the user's own.
+func (r *ChatRepository) AttachDetails(ctx context.Context, userId string, messages []Message) error {
+	messageIds := make([]string, len(messages))
+	for i, message := range messages {
+		messageIds[i] = message.Id
+	}
+
+	reactions, err := r.GetReactionSummaries(ctx, userId, messageIds)
+	if err != nil {
+		return err
+	}
+
+	mentions, err := r.GetMentions(ctx, messageIds)
+	if err != nil {
+		return err
+	}
+
+	attachments, err := r.GetAttachments(ctx, messageIds)
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		messages[i].Reactions = reactions[messages[i].Id]
+		messages[i].Mentions = mentions[messages[i].Id]
+		messages[i].Attachments = attachments[messages[i].Id]
+	}
+
+	return nil
+}
+
+// GetMentions returns the mentions of each message keyed by message id.
+func (r *ChatRepository) GetMentions(ctx context.Context, messageIds []string) (map[string][]Mention, error) {
+	mentions := make(map[string][]Mention)
+	if len(messageIds) == 0 {
+		return mentions, nil
+	}
+
+	rows, err := r.pool.Query(ctx, getMentionsByMessageIdsQuery, messageIds)
+	if err != nil {
+		slog.Error("[ChatRepository-GetMentions]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageId string
+		var mention Mention
+		if err := rows.Scan(&messageId, &mention.UserId, &mention.Offset, &mention.Length); err != nil {
+			slog.Error("[ChatRepository-GetMentions]", "Error", err)
+			return nil, err
+		}
+		mentions[messageId] = append(mentions[messageId], mention)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetMentions]", "Error", err)
+		return nil, err
+	}
+
+	return mentions, nil
+}
+
+// GetMessagesMentioningUser returns up to limit messages of the user's chats
+// that mention it, newest first, starting before the before message or from
+// the latest one when it is empty.
+func (r *ChatRepository) GetMessagesMentioningUser(ctx context.Context, userId string, before string, limit int) ([]Message, error) {
+	rows, err := r.pool.Query(ctx, getMessagesMentioningUserQuery, userId, before, limit)
+	if err != nil {
+		slog.Error("[ChatRepository-GetMessagesMentioningUser]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var message Message
+		if err := scanMessage(rows, &message); err != nil {
+			slog.Error("[ChatRepository-GetMessagesMentioningUser]", "Error", err)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetMessagesMentioningUser]", "Error", err)
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// SearchMessages returns the messages of the chats of the user matching the
+// filter, newest first, each with a headline of the matching content.
+func (r *ChatRepository) SearchMessages(ctx context.Context, userId string, filter SearchFilter, limit int) ([]SearchResult, error) {
+	rows, err := r.pool.Query(ctx, searchMessagesQuery,
+		userId,
+		filter.Query,
+		filter.ChatId,
+		filter.AuthorId,
+		filter.Since,
+		filter.Until,
+		filter.HasAttachment,
+		filter.Before,
+		limit,
+		headlineOptions,
+	)
+	if err != nil {
+		slog.Error("[ChatRepository-SearchMessages]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		var headline string
+		if err := scanMessage(rows, &result.Message, &headline); err != nil {
+			slog.Error("[ChatRepository-SearchMessages]", "Error", err)
+			return nil, err
+		}
+		result.Snippet, result.Highlights = ParseHeadline(headline)
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-SearchMessages]", "Error", err)
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func scanAttachment(row pgx.Row, attachment *Attachment) error {
+	err := row.Scan(
+		&attachment.Id,
+		&attachment.MessageId,
+		&attachment.ChatId,
+		&attachment.UploaderId,
+		&attachment.Filename,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.Sha256,
+		&attachment.CreatedAt,
+		&attachment.Width,
+		&attachment.Height,
+		&attachment.Blurhash,
+		&attachment.ThumbnailSha256,
+	)
+	if err != nil {
+		return err
+	}
+
+	attachment.Url = attachmentUrl(attachment.ChatId, attachment.Id)
+	if attachment.ThumbnailSha256 != nil {
+		attachment.ThumbnailUrl = thumbnailUrl(attachment.ChatId, attachment.Id)
+	}
+	return nil
+}
+
+// SaveAttachment records an upload whose content is stored already. Images
+// are queued for the thumbnail worker.
+func (r *ChatRepository) SaveAttachment(ctx context.Context, attachment Attachment) (Attachment, error) {
+	var thumbnailStatus *string
+	if thumbnailContentTypes[attachment.ContentType] {
+		status := thumbnailPending
+		thumbnailStatus = &status
+	}
+
+	var saved Attachment
+	err := scanAttachment(r.pool.QueryRow(ctx, saveAttachmentQuery,
+		attachment.ChatId,
+		attachment.UploaderId,
+		attachment.Filename,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.Sha256,
+		thumbnailStatus,
+	), &saved)
+	if err != nil {
+		slog.Error("[ChatRepository-SaveAttachment]", "Error", err)
+		return Attachment{}, err
+	}
+
+	return saved, nil
+}
+
+func (r *ChatRepository) GetAttachmentById(ctx context.Context, attachmentId string) (Attachment, error) {
+	var attachment Attachment
+	err := scanAttachment(r.pool.QueryRow(ctx, getAttachmentByIdQuery, attachmentId), &attachment)
+	if err != nil {
+		slog.Error("[ChatRepository-GetAttachmentById]", "Error", err)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Attachment{}, &AttachmentDoesNotExistError{}
+		}
+
+		return Attachment{}, err
+	}
+
+	return attachment, nil
+}
+
+// GetAttachments returns the attachments of each message keyed by message id.
+func (r *ChatRepository) GetAttachments(ctx context.Context, messageIds []string) (map[string][]Attachment, error) {
+	attachments := make(map[string][]Attachment)
+	if len(messageIds) == 0 {
+		return attachments, nil
+	}
+
+	rows, err := r.pool.Query(ctx, getAttachmentsByMessageIdsQuery, messageIds)
+	if err != nil {
+		slog.Error("[ChatRepository-GetAttachments]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attachment Attachment
+		if err := scanAttachment(rows, &attachment); err != nil {
+			slog.Error("[ChatRepository-GetAttachments]", "Error", err)
+			return nil, err
+		}
+		attachments[*attachment.MessageId] = append(attachments[*attachment.MessageId], attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetAttachments]", "Error", err)
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// ClaimThumbnailJobs claims up to limit attachments waiting for a thumbnail.
+// A claim expires after lease so that jobs of a crashed worker are picked up
+// again, and claimed jobs are skipped by other workers meanwhile. Jobs that
+// were claimed maxAttempts times already are failed instead.
+func (r *ChatRepository) ClaimThumbnailJobs(ctx context.Context, limit int, lease time.Duration, maxAttempts int) ([]Attachment, error) {
+	rows, err := r.pool.Query(ctx, claimThumbnailJobsQuery, limit, lease, maxAttempts)
+	if err != nil {
+		slog.Error("[ChatRepository-ClaimThumbnailJobs]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		var attachment Attachment
+		if err := scanAttachment(rows, &attachment); err != nil {
+			slog.Error("[ChatRepository-ClaimThumbnailJobs]", "Error", err)
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-ClaimThumbnailJobs]", "Error", err)
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// CompleteThumbnail records the dimensions, blurhash and thumbnail of an
+// image. Completing it again with the same values is harmless.
+func (r *ChatRepository) CompleteThumbnail(ctx context.Context, attachmentId string, thumbnail Thumbnail) error {
+	_, err := r.pool.Exec(ctx, completeThumbnailQuery,
+		attachmentId,
+		thumbnail.Width,
+		thumbnail.Height,
+		thumbnail.Blurhash,
+		thumbnail.Sha256,
+	)
+	if err != nil {
+		slog.Error("[ChatRepository-CompleteThumbnail]", "Error", err)
+		return err
+	}
+
+	return nil
+}
+
+// FailThumbnail gives up on the thumbnail of an attachment. With retry it is
+// left pending for another attempt once its claim expires, unless it ran out
+// of attempts.
+func (r *ChatRepository) FailThumbnail(ctx context.Context, attachmentId string, retry bool, maxAttempts int) error {
+	_, err := r.pool.Exec(ctx, failThumbnailQuery, attachmentId, retry, maxAttempts)
+	if err != nil {
+		slog.Error("[ChatRepository-FailThumbnail]", "Error", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetReadyThumbnail returns the thumbnail generated for another upload of the
+// same content, if any.
+func (r *ChatRepository) GetReadyThumbnail(ctx context.Context, sha256 string) (Thumbnail, bool, error) {
+	var thumbnail Thumbnail
+	err := r.pool.QueryRow(ctx, getReadyThumbnailQuery, sha256).Scan(
+		&thumbnail.Width,
+		&thumbnail.Height,
+		&thumbnail.Blurhash,
+		&thumbnail.Sha256,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Thumbnail{}, false, nil
+		}
+
+		slog.Error("[ChatRepository-GetReadyThumbnail]", "Error", err)
+		return Thumbnail{}, false, err
+	}
+
+	return thumbnail, true, nil
+}
+
+func scanInvite(row pgx.Row, invite *ChatInvite) error {
+	return row.Scan(
+		&invite.Id,
+		&invite.ChatId,
+		&invite.CreatedBy,
+		&invite.ExpiresAt,
+		&invite.MaxUses,
+		&invite.UseCount,
+		&invite.CreatedAt,
+		&invite.RevokedAt,
+	)
+}
+
+// SaveInvite records an invite to the chat under the hash of its token. It
+// expires after expiresIn unless that is nil and can be used maxUses times
+// unless that is nil.
+func (r *ChatRepository) SaveInvite(ctx context.Context, chatId string, creatorId string, tokenHash string, expiresIn *time.Duration, maxUses *int) (ChatInvite, error) {
+	var invite ChatInvite
+	err := scanInvite(r.pool.QueryRow(ctx, saveInviteQuery, chatId, creatorId, tokenHash, expiresIn, maxUses), &invite)
+	if err != nil {
+		slog.Error("[ChatRepository-SaveInvite]", "Error", err)
+		return ChatInvite{}, err
+	}
+
+	return invite, nil
+}
+
+// GetInvites returns every invite of the chat, revoked and expired ones
+// included, newest first.
+func (r *ChatRepository) GetInvites(ctx context.Context, chatId string) ([]ChatInvite, error) {
+	rows, err := r.pool.Query(ctx, getInvitesByChatIdQuery, chatId)
+	if err != nil {
+		slog.Error("[ChatRepository-GetInvites]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []ChatInvite{}
+	for rows.Next() {
+		var invite ChatInvite
+		if err := scanInvite(rows, &invite); err != nil {
+			slog.Error("[ChatRepository-GetInvites]", "Error", err)
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetInvites]", "Error", err)
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+// RevokeInvite stops an invite of the chat from being used. Revoking it again
+// keeps the time and user of the first revocation.
+func (r *ChatRepository) RevokeInvite(ctx context.Context, chatId string, inviteId string, actorId string) error {
+	var revokedId string
+	err := r.pool.QueryRow(ctx, revokeInviteQuery, inviteId, chatId, actorId).Scan(&revokedId)
+	if err != nil {
+		slog.Error("[ChatRepository-RevokeInvite]", "Error", err)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &InviteDoesNotExistError{}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// JoinChatByInvite adds the user to the chat of the invite with the given
+// token hash, records the use and a system message, which is returned along
+// with the id of the chat. The message is nil when the user was a member
+// already, in which case the invite is not used up.
+func (r *ChatRepository) JoinChatByInvite(ctx context.Context, userId string, tokenHash string) (string, *Message, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-JoinChatByInvite]", "Error", err)
+		return "", nil, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-JoinChatByInvite]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	// Locked so that concurrent joins cannot exceed the maximum uses
+	var inviteId, chatId string
+	err = tx.QueryRow(ctx, getValidInviteForUpdateQuery, tokenHash).Scan(&inviteId, &chatId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = &InviteDoesNotExistError{}
+		}
+
+		return "", nil, err
+	}
+
+	var memberChatId, memberUserId string
+	err = tx.QueryRow(ctx, isMemberOfChatByIdQuery, userId, chatId).Scan(&memberChatId, &memberUserId)
+	if err == nil {
+		return chatId, nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, err
+	}
+
+	var addedUserId string
+	err = tx.QueryRow(ctx, addChatMemberByIdQuery, chatId, userId, MemberRole).
+		Scan(&addedUserId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = &UserDoesNotExistError{}
+		}
+
+		return "", nil, err
+	}
+
+	_, err = tx.Exec(ctx, useInviteQuery, inviteId, userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var message Message
+	metadata := map[string]string{"invite_id": inviteId}
+	err = scanMessage(tx.QueryRow(ctx, saveMessageQuery, userId, chatId, SystemMessage, MemberJoinedAction, metadata, ""), &message)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return chatId, &message, nil
+}
+
+// GetInviteUses returns who joined through an invite of the chat and when,
+// oldest first.
+func (r *ChatRepository) GetInviteUses(ctx context.Context, chatId string, inviteId string) ([]InviteUse, error) {
+	rows, err := r.pool.Query(ctx, getInviteUsesQuery, inviteId, chatId)
+	if err != nil {
+		slog.Error("[ChatRepository-GetInviteUses]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	uses := []InviteUse{}
+	for rows.Next() {
+		var use InviteUse
+		if err := rows.Scan(&use.InviteId, &use.UserId, &use.UsedAt); err != nil {
+			slog.Error("[ChatRepository-GetInviteUses]", "Error", err)
+			return nil, err
+		}
+		uses = append(uses, use)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetInviteUses]", "Error", err)
+		return nil, err
+	}
+
+	return uses, nil
+}
+
+// DiscoverChats returns chats that are not private and whose name contains
+// the query, newest first, along with whether the user is a member of them.
+func (r *ChatRepository) DiscoverChats(ctx context.Context, userId string, query string, before pgtype.Timestamp, beforeChatId string, limit int) ([]DiscoveredChat, error) {
+	var beforeChatIdArg *string
+	if before.Valid {
+		beforeChatIdArg = &beforeChatId
+	}
+
+	rows, err := r.pool.Query(ctx, discoverChatsQuery, userId, escapeLike(query), before, beforeChatIdArg, limit)
+	if err != nil {
+		slog.Error("[ChatRepository-DiscoverChats]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	chats := []DiscoveredChat{}
+	for rows.Next() {
+		var chat DiscoveredChat
+		err := rows.Scan(
+			&chat.Id,
+			&chat.Name,
+			&chat.Topic,
+			&chat.Description,
+			&chat.Visibility,
+			&chat.MemberCount,
+			&chat.IsMember,
+			&chat.CreatedAt,
+		)
+		if err != nil {
+			slog.Error("[ChatRepository-DiscoverChats]", "Error", err)
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-DiscoverChats]", "Error", err)
+		return nil, err
+	}
+
+	return chats, nil
+}
+
+// JoinPublicChat adds the user to the chat as a member and records it as a
+// system message, which is returned. Chats that are not public (any longer)
+// are reported as not existing.
+func (r *ChatRepository) JoinPublicChat(ctx context.Context, userId string, chatId string) (Message, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-JoinPublicChat]", "Error", err)
+		return Message{}, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-JoinPublicChat]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	err = checkVisibility(ctx, tx, chatId, PublicChat)
+	if err != nil {
+		return Message{}, err
+	}
+
+	err = addMember(ctx, tx, chatId, userId)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var message Message
+	err = scanMessage(tx.QueryRow(ctx, saveMessageQuery, userId, chatId, SystemMessage, MemberJoinedAction, nil, ""), &message)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return message, nil
+}
+
+// checkVisibility locks the chat within the transaction, so that its
+// visibility cannot change until the transaction ends, and makes sure it is
+// the given one.
+func checkVisibility(ctx context.Context, tx pgx.Tx, chatId string, visibility ChatVisibility) error {
+	var current ChatVisibility
+	err := tx.QueryRow(ctx, lockChatQuery, chatId).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && current != visibility) {
+		return &ChatDoesNotExistError{}
+	}
+
+	return err
+}
+
+func scanJoinRequest(row pgx.Row, request *JoinRequest) error {
+	return row.Scan(
+		&request.Id,
+		&request.ChatId,
+		&request.UserId,
+		&request.Status,
+		&request.CreatedAt,
+		&request.DecidedBy,
+		&request.DecidedAt,
+	)
+}
+
+// SaveJoinRequest asks for the user to join the chat, which must be a
+// request chat. Asking again while the request is pending returns the pending
+// request.
+func (r *ChatRepository) SaveJoinRequest(ctx context.Context, chatId string, userId string) (JoinRequest, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-SaveJoinRequest]", "Error", err)
+		return JoinRequest{}, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-SaveJoinRequest]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	err = checkVisibility(ctx, tx, chatId, RequestChat)
+	if err != nil {
+		return JoinRequest{}, err
+	}
+
+	var request JoinRequest
+	for range maxJoinRequestAttempts {
+		err = scanJoinRequest(tx.QueryRow(ctx, saveJoinRequestQuery, chatId, userId), &request)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			break
+		}
+
+		err = scanJoinRequest(tx.QueryRow(ctx, getPendingJoinRequestQuery, chatId, userId), &request)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			break
+		}
+
+		// The pending request was decided in between, which leaves room
+		// for a new one
+	}
+	if err != nil {
+		return JoinRequest{}, err
+	}
+
+	return request, nil
+}
+
+// GetJoinRequests returns the pending join requests of the chat, oldest
+// first.
+func (r *ChatRepository) GetJoinRequests(ctx context.Context, chatId string) ([]JoinRequest, error) {
+	rows, err := r.pool.Query(ctx, getJoinRequestsByChatIdQuery, chatId)
+	if err != nil {
+		slog.Error("[ChatRepository-GetJoinRequests]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []JoinRequest{}
+	for rows.Next() {
+		var request JoinRequest
+		if err := scanJoinRequest(rows, &request); err != nil {
+			slog.Error("[ChatRepository-GetJoinRequests]", "Error", err)
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[ChatRepository-GetJoinRequests]", "Error", err)
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// ApproveJoinRequest approves a pending join request of the chat, adds its
+// user to the chat and records it as a system message on behalf of actorId.
+// The message is nil when the user became a member in the meantime.
+func (r *ChatRepository) ApproveJoinRequest(ctx context.Context, actorId string, chatId string, requestId string) (JoinRequest, *Message, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[ChatRepository-ApproveJoinRequest]", "Error", err)
+		return JoinRequest{}, nil, err
+	}
+	defer func() {
+		if err != nil {
+			slog.Error("[ChatRepository-ApproveJoinRequest]", "Error", err)
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	var request JoinRequest
+	err = scanJoinRequest(tx.QueryRow(ctx, decideJoinRequestQuery, requestId, chatId, ApprovedJoinRequest, actorId), &request)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = &JoinRequestDoesNotExistError{}
+		}
+
+		return JoinRequest{}, nil, err
+	}
+
+	var memberChatId, memberUserId string
+	err = tx.QueryRow(ctx, isMemberOfChatByIdQuery, request.UserId, chatId).Scan(&memberChatId, &memberUserId)
+	if err == nil {
+		return request, nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return JoinRequest{}, nil, err
+	}
+
+	err = addMember(ctx, tx, chatId, request.UserId)
+	if err != nil {
+		return JoinRequest{}, nil, err
+	}
+
+	var message Message
+	metadata := map[string]string{"target_user_id": request.UserId, "join_request_id": request.Id}
+	err = scanMessage(tx.QueryRow(ctx, saveMessageQuery, actorId, chatId, SystemMessage, MemberAddedAction, metadata, ""), &message)
+	if err != nil {
+		return JoinRequest{}, nil, err
+	}
+
+	return request, &message, nil
+}
+
+// RejectJoinRequest rejects a pending join request of the chat on behalf of
+// actorId.
+func (r *ChatRepository) RejectJoinRequest(ctx context.Context, actorId string, chatId string, requestId string) (JoinRequest, error) {
+	var request JoinRequest
+	err := scanJoinRequest(r.pool.QueryRow(ctx, decideJoinRequestQuery, requestId, chatId, RejectedJoinRequest, actorId), &request)
+	if err != nil {
+		slog.Error("[ChatRepository-RejectJoinRequest]", "Error", err)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return JoinRequest{}, &JoinRequestDoesNotExistError{}
+		}
+
+		return JoinRequest{}, err
+	}
+
+	return request, nil
+}
@@ -7,14 +7,19 @@ import (
 	"go_chat/internal/user"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
+// Repositories store whatever hash they are given
+const testPasswordHash = "test_password_hash"
+
 type TestDbContainer struct {
 	Container testcontainers.Container
 	Pool      *pgxpool.Pool
@@ -91,7 +96,7 @@ func TestRepository_CreateChat(t *testing.T) {
 
 	username := "test_user"
 	email := "test@example.org"
-	testUser, err := userRepo.CreateUser(ctx, username, email)
+	testUser, err := userRepo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	t.Run("create chat with single user", func(t *testing.T) {
@@ -101,7 +106,7 @@ func TestRepository_CreateChat(t *testing.T) {
 
 	t.Run("create chat with multiple users", func(t *testing.T) {
 		username := "test_user2"
-		otherUser, err := userRepo.CreateUser(ctx, username, email)
+		otherUser, err := userRepo.CreateUser(ctx, username, email, testPasswordHash)
 		require.NoError(t, err)
 
 		_, err = chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
@@ -113,6 +118,30 @@ func TestRepository_CreateChat(t *testing.T) {
 		require.Error(t, err)
 		require.ErrorIs(t, err, &chat.NoUserIdProvidedError{})
 	})
+
+	t.Run("create direct chat", func(t *testing.T) {
+		otherUser, err := userRepo.CreateUser(ctx, "test_user3", email, testPasswordHash)
+		require.NoError(t, err)
+
+		direct, err := chatRepo.SaveDirectChat(ctx, testUser.Id, otherUser.Id)
+		require.NoError(t, err)
+		require.Equal(t, chat.DirectChat, direct.Kind)
+
+		// Either user gets the same chat
+		again, err := chatRepo.SaveDirectChat(ctx, otherUser.Id, testUser.Id)
+		require.NoError(t, err)
+		require.Equal(t, direct.Id, again.Id)
+
+		saved, err := chatRepo.GetChatById(ctx, direct.Id)
+		require.NoError(t, err)
+		require.Equal(t, chat.DirectChat, saved.Kind)
+		require.ElementsMatch(t, []string{testUser.Id, otherUser.Id}, saved.Members)
+	})
+
+	t.Run("create direct chat with unknown user", func(t *testing.T) {
+		_, err := chatRepo.SaveDirectChat(ctx, testUser.Id, uuid.NewString())
+		require.ErrorIs(t, err, &chat.UserDoesNotExistError{})
+	})
 }
 
 func TestRepository_SaveMessage(t *testing.T) {
@@ -126,7 +155,7 @@ func TestRepository_SaveMessage(t *testing.T) {
 
 	username := "test_user"
 	email := "test@example.org"
-	testUser, err := userRepo.CreateUser(ctx, username, email)
+	testUser, err := userRepo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
@@ -161,7 +190,7 @@ func TestRepository_GetMessages(t *testing.T) {
 
 	username := "test_user"
 	email := "test@example.org"
-	testUser, err := userRepo.CreateUser(ctx, username, email)
+	testUser, err := userRepo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
@@ -178,32 +207,38 @@ func TestRepository_GetMessages(t *testing.T) {
 	require.Equal(t, message.UserId, testUser.Id)
 
 	t.Run("get messages", func(t *testing.T) {
-		msgCount := 30
-		offset := 0
-
-		messages, err := chatRepo.GetMessages(ctx, c.Id, msgCount, offset)
+		messages, err := chatRepo.GetMessages(ctx, testUser.Id, c.Id, "", "", 30)
 		require.NoError(t, err)
+		require.Len(t, messages, 2)
 		require.Equal(t, messages[1].Content, message.Content)
 	})
 
-	t.Run("get messages with offset", func(t *testing.T) {
-		msgCount := 1
-		offset := 1
+	t.Run("get latest messages", func(t *testing.T) {
+		messages, err := chatRepo.GetMessages(ctx, testUser.Id, c.Id, "", "", 1)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Id, message.Id)
+	})
+
+	t.Run("get messages before and after cursor", func(t *testing.T) {
+		messages, err := chatRepo.GetMessages(ctx, testUser.Id, c.Id, message.Id, "", 30)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Content, "This is the first test message")
 
-		messages, err := chatRepo.GetMessages(ctx, c.Id, msgCount, offset)
+		messages, err = chatRepo.GetMessages(ctx, testUser.Id, c.Id, "", messages[0].Id, 30)
 		require.NoError(t, err)
-		require.Equal(t, messages[0].Content, message.Content)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Id, message.Id)
 	})
 
 	t.Run("get messages without message", func(t *testing.T) {
 		c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
 		require.NoError(t, err)
 
-		msgCount := 30
-		offset := 0
-
-		_, err = chatRepo.GetMessages(ctx, c.Id, msgCount, offset)
+		messages, err := chatRepo.GetMessages(ctx, testUser.Id, c.Id, "", "", 30)
 		require.NoError(t, err)
+		require.Empty(t, messages)
 	})
 }
 
@@ -218,7 +253,7 @@ func TestRepository_IsMemberOfChatById(t *testing.T) {
 
 	username := "test_user"
 	email := "test@example.org"
-	testUser, err := userRepo.CreateUser(ctx, username, email)
+	testUser, err := userRepo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
@@ -248,3 +283,1317 @@ func TestRepository_IsMemberOfChatById(t *testing.T) {
 		require.Equal(t, isMember, false)
 	})
 }
+
+func TestRepository_GetMessagesAfterId(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	username := "test_user"
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, username, email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+
+	first, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is the first test message")
+	require.NoError(t, err)
+	second, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is the second test message")
+	require.NoError(t, err)
+
+	t.Run("get messages after id", func(t *testing.T) {
+		messages, err := chatRepo.GetMessagesAfterId(ctx, testUser.Id, c.Id, first.Id, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Id, second.Id)
+	})
+
+	t.Run("get messages after latest id", func(t *testing.T) {
+		messages, err := chatRepo.GetMessagesAfterId(ctx, testUser.Id, c.Id, second.Id, 10)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+	})
+
+	t.Run("get messages after id of another chat", func(t *testing.T) {
+		otherChat, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+		require.NoError(t, err)
+
+		messages, err := chatRepo.GetMessagesAfterId(ctx, testUser.Id, otherChat.Id, first.Id, 10)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+	})
+
+	t.Run("skip messages hidden by the user", func(t *testing.T) {
+		err := chatRepo.HideMessage(ctx, testUser.Id, second.Id)
+		require.NoError(t, err)
+
+		messages, err := chatRepo.GetMessagesAfterId(ctx, testUser.Id, c.Id, first.Id, 10)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+	})
+
+	t.Run("get messages after id up to limit", func(t *testing.T) {
+		third, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is the third test message")
+		require.NoError(t, err)
+		fourth, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is the fourth test message")
+		require.NoError(t, err)
+
+		messages, err := chatRepo.GetMessagesAfterId(ctx, testUser.Id, c.Id, second.Id, 1)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Id, third.Id)
+
+		messages, err = chatRepo.GetMessagesAfterId(ctx, testUser.Id, c.Id, third.Id, 1)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Id, fourth.Id)
+	})
+}
+
+func TestRepository_GetMessageById(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	username := "test_user"
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, username, email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+
+	message, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is a test message")
+	require.NoError(t, err)
+
+	t.Run("get message by id", func(t *testing.T) {
+		messageFromDB, err := chatRepo.GetMessageById(ctx, message.Id)
+		require.NoError(t, err)
+		require.Equal(t, messageFromDB.Content, message.Content)
+	})
+
+	t.Run("get non-existing message by id", func(t *testing.T) {
+		_, err := chatRepo.GetMessageById(ctx, uuid.New().String())
+		require.Error(t, err)
+		require.ErrorIs(t, err, &chat.MessageDoesNotExistError{})
+	})
+}
+
+func TestRepository_MemberRoles(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	owner, err := userRepo.CreateUser(ctx, "test_owner", email, testPasswordHash)
+	require.NoError(t, err)
+	member, err := userRepo.CreateUser(ctx, "test_member", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{owner.Id, member.Id})
+	require.NoError(t, err)
+
+	t.Run("creator is the owner", func(t *testing.T) {
+		role, err := chatRepo.GetMemberRole(ctx, owner.Id, c.Id)
+		require.NoError(t, err)
+		require.Equal(t, role, chat.OwnerRole)
+
+		role, err = chatRepo.GetMemberRole(ctx, member.Id, c.Id)
+		require.NoError(t, err)
+		require.Equal(t, role, chat.MemberRole)
+	})
+
+	t.Run("get role of non-member", func(t *testing.T) {
+		_, err := chatRepo.GetMemberRole(ctx, uuid.New().String(), c.Id)
+		require.Error(t, err)
+		require.ErrorIs(t, err, &chat.UserIsNotAMemberError{})
+	})
+
+	t.Run("promote member to admin", func(t *testing.T) {
+		role, err := chatRepo.UpdateMemberRole(ctx, c.Id, member.Id, chat.AdminRole)
+		require.NoError(t, err)
+		require.Equal(t, role, chat.AdminRole)
+	})
+
+	t.Run("chat cannot have two owners", func(t *testing.T) {
+		_, err := chatRepo.UpdateMemberRole(ctx, c.Id, member.Id, chat.OwnerRole)
+		require.Error(t, err)
+	})
+
+	t.Run("transfer ownership", func(t *testing.T) {
+		err := chatRepo.TransferOwnership(ctx, c.Id, owner.Id, member.Id)
+		require.NoError(t, err)
+
+		role, err := chatRepo.GetMemberRole(ctx, member.Id, c.Id)
+		require.NoError(t, err)
+		require.Equal(t, role, chat.OwnerRole)
+
+		role, err = chatRepo.GetMemberRole(ctx, owner.Id, c.Id)
+		require.NoError(t, err)
+		require.Equal(t, role, chat.AdminRole)
+	})
+
+	t.Run("transfer ownership to non-member", func(t *testing.T) {
+		err := chatRepo.TransferOwnership(ctx, c.Id, member.Id, uuid.New().String())
+		require.Error(t, err)
+		require.ErrorIs(t, err, &chat.UserIsNotAMemberError{})
+
+		role, err := chatRepo.GetMemberRole(ctx, member.Id, c.Id)
+		require.NoError(t, err)
+		require.Equal(t, role, chat.OwnerRole)
+	})
+}
+
+func TestRepository_ManageMembers(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	owner, err := userRepo.CreateUser(ctx, "test_owner", email, testPasswordHash)
+	require.NoError(t, err)
+	member, err := userRepo.CreateUser(ctx, "test_member", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{owner.Id})
+	require.NoError(t, err)
+
+	t.Run("add member", func(t *testing.T) {
+		message, err := chatRepo.AddMember(ctx, owner.Id, c.Id, member.Id)
+		require.NoError(t, err)
+		require.Equal(t, message.Kind, chat.SystemMessage)
+		require.Equal(t, message.Content, string(chat.MemberAddedAction))
+		require.Equal(t, message.Metadata["target_user_id"], member.Id)
+
+		count, err := chatRepo.CountMembers(ctx, c.Id)
+		require.NoError(t, err)
+		require.Equal(t, count, 2)
+	})
+
+	t.Run("add existing member", func(t *testing.T) {
+		_, err := chatRepo.AddMember(ctx, owner.Id, c.Id, member.Id)
+		require.Error(t, err)
+		require.ErrorIs(t, err, &chat.UserIsAlreadyAMemberError{})
+	})
+
+	t.Run("add non-existing user", func(t *testing.T) {
+		_, err := chatRepo.AddMember(ctx, owner.Id, c.Id, uuid.New().String())
+		require.Error(t, err)
+		require.ErrorIs(t, err, &chat.UserDoesNotExistError{})
+	})
+
+	t.Run("remove member", func(t *testing.T) {
+		message, err := chatRepo.RemoveMember(ctx, owner.Id, c.Id, member.Id)
+		require.NoError(t, err)
+		require.Equal(t, message.Content, string(chat.MemberRemovedAction))
+
+		_, err = chatRepo.IsMemberOfChatById(ctx, member.Id, c.Id)
+		require.ErrorIs(t, err, &chat.UserIsNotAMemberError{})
+	})
+
+	t.Run("leave chat", func(t *testing.T) {
+		_, err := chatRepo.AddMember(ctx, owner.Id, c.Id, member.Id)
+		require.NoError(t, err)
+
+		message, err := chatRepo.RemoveMember(ctx, member.Id, c.Id, member.Id)
+		require.NoError(t, err)
+		require.Equal(t, message.Content, string(chat.MemberLeftAction))
+	})
+
+	t.Run("remove non-member", func(t *testing.T) {
+		_, err := chatRepo.RemoveMember(ctx, owner.Id, c.Id, member.Id)
+		require.Error(t, err)
+		require.ErrorIs(t, err, &chat.UserIsNotAMemberError{})
+	})
+}
+
+func TestRepository_GetChatsByUserId(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+
+	quietChat, err := chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
+	require.NoError(t, err)
+	activeChat, err := chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
+	require.NoError(t, err)
+
+	firstMessage, err := chatRepo.SaveMessage(ctx, otherUser.Id, activeChat.Id, "This is the first test message")
+	require.NoError(t, err)
+	lastMessage, err := chatRepo.SaveMessage(ctx, otherUser.Id, activeChat.Id, "This is the second test message")
+	require.NoError(t, err)
+
+	t.Run("get chats ordered by last activity", func(t *testing.T) {
+		chats, err := chatRepo.GetChatsByUserId(ctx, testUser.Id, pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Len(t, chats, 2)
+
+		require.Equal(t, chats[0].Id, activeChat.Id)
+		require.Len(t, chats[0].Members, 2)
+		require.NotNil(t, chats[0].LastMessage)
+		require.Equal(t, chats[0].LastMessage.Id, lastMessage.Id)
+		require.Equal(t, chats[0].UnreadCount, 2)
+
+		require.Equal(t, chats[1].Id, quietChat.Id)
+		require.Nil(t, chats[1].LastMessage)
+		require.Equal(t, chats[1].UnreadCount, 0)
+	})
+
+	t.Run("get chats before cursor", func(t *testing.T) {
+		chats, err := chatRepo.GetChatsByUserId(ctx, testUser.Id, pgtype.Timestamp{}, "", 1)
+		require.NoError(t, err)
+		require.Len(t, chats, 1)
+
+		chats, err = chatRepo.GetChatsByUserId(ctx, testUser.Id, chats[0].LastActivityAt, chats[0].Id, 10)
+		require.NoError(t, err)
+		require.Len(t, chats, 1)
+		require.Equal(t, chats[0].Id, quietChat.Id)
+	})
+
+	t.Run("own messages are not unread", func(t *testing.T) {
+		chats, err := chatRepo.GetChatsByUserId(ctx, otherUser.Id, pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Equal(t, chats[0].UnreadCount, 0)
+	})
+
+	t.Run("last message has its details", func(t *testing.T) {
+		_, err := chatRepo.AddReaction(ctx, chat.Reaction{MessageId: lastMessage.Id, UserId: testUser.Id, Emoji: "👍"})
+		require.NoError(t, err)
+
+		chats, err := chatRepo.GetChatsByUserId(ctx, testUser.Id, pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Len(t, chats[0].LastMessage.Reactions, 1)
+		require.Equal(t, chats[0].LastMessage.Reactions[0].Count, 1)
+		require.True(t, chats[0].LastMessage.Reactions[0].Reacted)
+	})
+
+	t.Run("thread replies are not the last message", func(t *testing.T) {
+		_, err := chatRepo.SaveReply(ctx, otherUser.Id, "This is a reply in a thread", lastMessage)
+		require.NoError(t, err)
+
+		chats, err := chatRepo.GetChatsByUserId(ctx, testUser.Id, pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Equal(t, chats[0].Id, activeChat.Id)
+		require.Equal(t, chats[0].LastMessage.Id, lastMessage.Id)
+		require.Equal(t, chats[0].LastActivityAt, lastMessage.CreatedAt)
+	})
+
+	t.Run("hidden messages are not the last message", func(t *testing.T) {
+		err := chatRepo.HideMessage(ctx, testUser.Id, lastMessage.Id)
+		require.NoError(t, err)
+
+		chats, err := chatRepo.GetChatsByUserId(ctx, testUser.Id, pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Equal(t, chats[0].Id, activeChat.Id)
+		require.Equal(t, chats[0].LastMessage.Id, firstMessage.Id)
+		require.Equal(t, chats[0].LastActivityAt, firstMessage.CreatedAt)
+
+		// Others still see it
+		chats, err = chatRepo.GetChatsByUserId(ctx, otherUser.Id, pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Equal(t, chats[0].LastMessage.Id, lastMessage.Id)
+	})
+}
+
+func TestRepository_MarkRead(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
+	require.NoError(t, err)
+
+	firstMessage, err := chatRepo.SaveMessage(ctx, otherUser.Id, c.Id, "This is the first test message")
+	require.NoError(t, err)
+	secondMessage, err := chatRepo.SaveMessage(ctx, otherUser.Id, c.Id, "This is the second test message")
+	require.NoError(t, err)
+
+	t.Run("mark message as read", func(t *testing.T) {
+		receipt, moved, err := chatRepo.MarkRead(ctx, testUser.Id, c.Id, firstMessage.Id)
+		require.NoError(t, err)
+		require.True(t, moved)
+		require.Equal(t, receipt.LastReadMessageId, firstMessage.Id)
+
+		chats, err := chatRepo.GetChatsByUserId(ctx, testUser.Id, pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Equal(t, chats[0].UnreadCount, 1)
+
+		readers, err := chatRepo.GetMessageReaders(ctx, c.Id, firstMessage.Id)
+		require.NoError(t, err)
+		require.Len(t, readers, 1)
+		require.Equal(t, readers[0].UserId, testUser.Id)
+
+		readers, err = chatRepo.GetMessageReaders(ctx, c.Id, secondMessage.Id)
+		require.NoError(t, err)
+		require.Empty(t, readers)
+	})
+
+	t.Run("read cursor does not move backwards", func(t *testing.T) {
+		_, moved, err := chatRepo.MarkRead(ctx, testUser.Id, c.Id, secondMessage.Id)
+		require.NoError(t, err)
+		require.True(t, moved)
+
+		_, moved, err = chatRepo.MarkRead(ctx, testUser.Id, c.Id, firstMessage.Id)
+		require.NoError(t, err)
+		require.False(t, moved)
+
+		readers, err := chatRepo.GetMessageReaders(ctx, c.Id, secondMessage.Id)
+		require.NoError(t, err)
+		require.Len(t, readers, 1)
+	})
+
+	t.Run("mark message of another chat as read", func(t *testing.T) {
+		otherChat, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+		require.NoError(t, err)
+
+		_, moved, err := chatRepo.MarkRead(ctx, testUser.Id, otherChat.Id, secondMessage.Id)
+		require.NoError(t, err)
+		require.False(t, moved)
+	})
+}
+
+func TestRepository_EditMessage(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	testUser, err := userRepo.CreateUser(ctx, "test_user", "test@example.org", testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+
+	message, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is the first version")
+	require.NoError(t, err)
+	require.False(t, message.EditedAt.Valid)
+
+	t.Run("edit message", func(t *testing.T) {
+		edited, err := chatRepo.EditMessage(ctx, message.Id, "This is the second version")
+		require.NoError(t, err)
+		require.Equal(t, edited.Content, "This is the second version")
+		require.True(t, edited.EditedAt.Valid)
+
+		edited, err = chatRepo.EditMessage(ctx, message.Id, "This is the third version")
+		require.NoError(t, err)
+
+		revisions, err := chatRepo.GetMessageRevisions(ctx, message.Id)
+		require.NoError(t, err)
+		require.Len(t, revisions, 2)
+		require.Equal(t, revisions[0].Content, "This is the first version")
+		require.Equal(t, revisions[1].Content, "This is the second version")
+
+		stored, err := chatRepo.GetMessageById(ctx, message.Id)
+		require.NoError(t, err)
+		require.Equal(t, stored.Content, edited.Content)
+	})
+
+	t.Run("edit message with empty content", func(t *testing.T) {
+		_, err := chatRepo.EditMessage(ctx, message.Id, "")
+		require.ErrorIs(t, err, &chat.MessageContentIsEmptyError{})
+	})
+
+	t.Run("edit message that does not exist", func(t *testing.T) {
+		_, err := chatRepo.EditMessage(ctx, uuid.New().String(), "This is a new version")
+		require.ErrorIs(t, err, &chat.MessageDoesNotExistError{})
+	})
+
+	t.Run("edit message deleted for everyone", func(t *testing.T) {
+		deleted, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This will be deleted")
+		require.NoError(t, err)
+		_, _, err = chatRepo.DeleteMessage(ctx, deleted.Id)
+		require.NoError(t, err)
+
+		_, err = chatRepo.EditMessage(ctx, deleted.Id, "This is a new version")
+		require.ErrorIs(t, err, &chat.MessageDoesNotExistError{})
+
+		revisions, err := chatRepo.GetMessageRevisions(ctx, deleted.Id)
+		require.NoError(t, err)
+		require.Empty(t, revisions)
+
+		stored, err := chatRepo.GetMessageById(ctx, deleted.Id)
+		require.NoError(t, err)
+		require.Empty(t, stored.Content)
+	})
+}
+
+func TestRepository_DeleteMessage(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
+	require.NoError(t, err)
+
+	t.Run("delete message for everyone", func(t *testing.T) {
+		message, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is a test message")
+		require.NoError(t, err)
+		_, err = chatRepo.EditMessage(ctx, message.Id, "This is an edited test message")
+		require.NoError(t, err)
+
+		deleted, ok, err := chatRepo.DeleteMessage(ctx, message.Id)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Empty(t, deleted.Content)
+		require.True(t, deleted.DeletedAt.Valid)
+
+		revisions, err := chatRepo.GetMessageRevisions(ctx, message.Id)
+		require.NoError(t, err)
+		require.Empty(t, revisions)
+
+		// The tombstone stays in the history of every member
+		messages, err := chatRepo.GetMessages(ctx, otherUser.Id, c.Id, "", "", 30)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Id, message.Id)
+
+		_, ok, err = chatRepo.DeleteMessage(ctx, message.Id)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("hide message for one member", func(t *testing.T) {
+		message, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is another test message")
+		require.NoError(t, err)
+
+		err = chatRepo.HideMessage(ctx, otherUser.Id, message.Id)
+		require.NoError(t, err)
+		// Hiding twice is not an error
+		err = chatRepo.HideMessage(ctx, otherUser.Id, message.Id)
+		require.NoError(t, err)
+
+		messages, err := chatRepo.GetMessages(ctx, otherUser.Id, c.Id, "", "", 30)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.NotEqual(t, messages[0].Id, message.Id)
+
+		messages, err = chatRepo.GetMessages(ctx, testUser.Id, c.Id, "", "", 30)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		require.Equal(t, messages[1].Id, message.Id)
+	})
+}
+
+func TestRepository_Reactions(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
+	require.NoError(t, err)
+
+	message, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is a test message")
+	require.NoError(t, err)
+
+	t.Run("add reactions", func(t *testing.T) {
+		added, err := chatRepo.AddReaction(ctx, chat.Reaction{MessageId: message.Id, UserId: testUser.Id, Emoji: "👍"})
+		require.NoError(t, err)
+		require.True(t, added)
+
+		added, err = chatRepo.AddReaction(ctx, chat.Reaction{MessageId: message.Id, UserId: otherUser.Id, Emoji: "👍"})
+		require.NoError(t, err)
+		require.True(t, added)
+
+		added, err = chatRepo.AddReaction(ctx, chat.Reaction{MessageId: message.Id, UserId: testUser.Id, Emoji: "👍"})
+		require.NoError(t, err)
+		require.False(t, added)
+
+		summaries, err := chatRepo.GetReactionSummaries(ctx, otherUser.Id, []string{message.Id})
+		require.NoError(t, err)
+		require.Len(t, summaries[message.Id], 1)
+		require.Equal(t, summaries[message.Id][0].Count, 2)
+		require.True(t, summaries[message.Id][0].Reacted)
+	})
+
+	t.Run("attach details without a user", func(t *testing.T) {
+		messages := []chat.Message{message}
+		err := chatRepo.AttachDetails(ctx, "", messages)
+		require.NoError(t, err)
+		require.Len(t, messages[0].Reactions, 1)
+		require.Equal(t, messages[0].Reactions[0].Count, 2)
+		require.False(t, messages[0].Reactions[0].Reacted)
+	})
+
+	t.Run("remove reaction", func(t *testing.T) {
+		removed, err := chatRepo.RemoveReaction(ctx, chat.Reaction{MessageId: message.Id, UserId: otherUser.Id, Emoji: "👍"})
+		require.NoError(t, err)
+		require.True(t, removed)
+
+		removed, err = chatRepo.RemoveReaction(ctx, chat.Reaction{MessageId: message.Id, UserId: otherUser.Id, Emoji: "👍"})
+		require.NoError(t, err)
+		require.False(t, removed)
+
+		summaries, err := chatRepo.GetReactionSummaries(ctx, otherUser.Id, []string{message.Id})
+		require.NoError(t, err)
+		require.Equal(t, summaries[message.Id][0].Count, 1)
+		require.False(t, summaries[message.Id][0].Reacted)
+	})
+
+	t.Run("cap distinct reactions", func(t *testing.T) {
+		message, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is another test message")
+		require.NoError(t, err)
+
+		// Playing cards are all distinct emoji
+		for i := range 20 {
+			emoji := chat.Emoji(string(rune(0x1f0a1 + i)))
+			_, err := chatRepo.AddReaction(ctx, chat.Reaction{MessageId: message.Id, UserId: testUser.Id, Emoji: emoji})
+			require.NoError(t, err)
+		}
+
+		_, err = chatRepo.AddReaction(ctx, chat.Reaction{MessageId: message.Id, UserId: testUser.Id, Emoji: "👍"})
+		require.ErrorIs(t, err, &chat.TooManyReactionsError{})
+
+		// Existing emoji can still be reacted with
+		added, err := chatRepo.AddReaction(ctx, chat.Reaction{MessageId: message.Id, UserId: otherUser.Id, Emoji: "🂡"})
+		require.NoError(t, err)
+		require.True(t, added)
+	})
+
+	t.Run("react to message deleted for everyone", func(t *testing.T) {
+		message, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is a deleted test message")
+		require.NoError(t, err)
+		_, _, err = chatRepo.DeleteMessage(ctx, message.Id)
+		require.NoError(t, err)
+
+		_, err = chatRepo.AddReaction(ctx, chat.Reaction{MessageId: message.Id, UserId: otherUser.Id, Emoji: "👍"})
+		require.ErrorIs(t, err, &chat.MessageDoesNotExistError{})
+	})
+}
+
+func TestRepository_Threads(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	testUser, err := userRepo.CreateUser(ctx, "test_user", "test@example.org", testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+
+	root, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is the root message")
+	require.NoError(t, err)
+
+	t.Run("reply to messages", func(t *testing.T) {
+		reply, err := chatRepo.SaveReply(ctx, testUser.Id, "This is a reply", root)
+		require.NoError(t, err)
+		require.Equal(t, *reply.ReplyToId, root.Id)
+		require.Equal(t, *reply.ThreadRootId, root.Id)
+
+		// Replying to a reply stays in the same thread
+		nested, err := chatRepo.SaveReply(ctx, testUser.Id, "This is a reply to the reply", reply)
+		require.NoError(t, err)
+		require.Equal(t, *nested.ReplyToId, reply.Id)
+		require.Equal(t, *nested.ThreadRootId, root.Id)
+
+		root, err := chatRepo.GetMessageById(ctx, root.Id)
+		require.NoError(t, err)
+		require.Equal(t, root.ThreadReplyCount, 2)
+		require.Equal(t, root.LastReplyAt.Time, nested.CreatedAt.Time)
+	})
+
+	t.Run("get thread messages", func(t *testing.T) {
+		messages, err := chatRepo.GetThreadMessages(ctx, testUser.Id, root.Id, "", 30)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		require.Equal(t, messages[0].Content, "This is a reply")
+
+		messages, err = chatRepo.GetThreadMessages(ctx, testUser.Id, root.Id, messages[0].Id, 30)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Content, "This is a reply to the reply")
+	})
+
+	t.Run("replies are left out of the chat history", func(t *testing.T) {
+		messages, err := chatRepo.GetMessages(ctx, testUser.Id, c.Id, "", "", 30)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Id, root.Id)
+	})
+}
+
+func TestRepository_Mentions(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
+	require.NoError(t, err)
+
+	message, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "@test_user2 have a look")
+	require.NoError(t, err)
+
+	t.Run("resolve mentions when saving a message", func(t *testing.T) {
+		require.Equal(t, []chat.Mention{{UserId: otherUser.Id, Offset: 0, Length: 11}}, message.Mentions)
+
+		outsider, err := userRepo.CreateUser(ctx, "outsider", email, testPasswordHash)
+		require.NoError(t, err)
+
+		// Only members of the chat can be mentioned
+		other, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "@outsider and @nobody, meet @test_user")
+		require.NoError(t, err)
+		require.Equal(t, []chat.Mention{{UserId: testUser.Id, Offset: 28, Length: 10}}, other.Mentions)
+		require.NotEqual(t, outsider.Id, other.Mentions[0].UserId)
+
+		edited, err := chatRepo.EditMessage(ctx, other.Id, "never mind")
+		require.NoError(t, err)
+		require.Empty(t, edited.Mentions)
+
+		mentions, err := chatRepo.GetMentions(ctx, []string{other.Id})
+		require.NoError(t, err)
+		require.Empty(t, mentions[other.Id])
+	})
+
+	t.Run("save mentions", func(t *testing.T) {
+		mentions, err := chatRepo.GetMentions(ctx, []string{message.Id})
+		require.NoError(t, err)
+		require.Equal(t, mentions[message.Id], []chat.Mention{{UserId: otherUser.Id, Offset: 0, Length: 11}})
+	})
+
+	t.Run("get messages mentioning user", func(t *testing.T) {
+		messages, err := chatRepo.GetMessagesMentioningUser(ctx, otherUser.Id, "", 30)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Id, message.Id)
+
+		messages, err = chatRepo.GetMessagesMentioningUser(ctx, testUser.Id, "", 30)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+	})
+
+	t.Run("replace mentions", func(t *testing.T) {
+		edited, err := chatRepo.EditMessage(ctx, message.Id, "@test_user have a look")
+		require.NoError(t, err)
+		require.Equal(t, []chat.Mention{{UserId: testUser.Id, Offset: 0, Length: 10}}, edited.Mentions)
+
+		messages, err := chatRepo.GetMessagesMentioningUser(ctx, otherUser.Id, "", 30)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+
+		messages, err = chatRepo.GetMessagesMentioningUser(ctx, testUser.Id, "", 30)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, messages[0].Id, message.Id)
+	})
+}
+
+func TestRepository_Attachments(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
+	require.NoError(t, err)
+
+	upload := chat.Attachment{
+		ChatId:      c.Id,
+		UploaderId:  testUser.Id,
+		Filename:    "photo.png",
+		ContentType: "image/png",
+		Size:        1024,
+		Sha256:      "0f343b0931126a20f133d67c2b018a3b0f343b0931126a20f133d67c2b018a3b",
+	}
+
+	t.Run("send message with attachments", func(t *testing.T) {
+		attachment, err := chatRepo.SaveAttachment(ctx, upload)
+		require.NoError(t, err)
+		require.Nil(t, attachment.MessageId)
+		require.NotEmpty(t, attachment.Url)
+
+		// Attachments can be sent without any text
+		message, _, err := chatRepo.SaveMessageWithAttachments(ctx, testUser.Id, c.Id, "", "", nil, []string{attachment.Id})
+		require.NoError(t, err)
+		require.Len(t, message.Attachments, 1)
+		require.Equal(t, *message.Attachments[0].MessageId, message.Id)
+
+		attachments, err := chatRepo.GetAttachments(ctx, []string{message.Id})
+		require.NoError(t, err)
+		require.Len(t, attachments[message.Id], 1)
+
+		// Sent attachments cannot be sent again
+		_, _, err = chatRepo.SaveMessageWithAttachments(ctx, testUser.Id, c.Id, "", "", nil, []string{attachment.Id})
+		require.ErrorIs(t, err, &chat.AttachmentDoesNotExistError{})
+	})
+
+	t.Run("send attachment of another user", func(t *testing.T) {
+		attachment, err := chatRepo.SaveAttachment(ctx, upload)
+		require.NoError(t, err)
+
+		_, _, err = chatRepo.SaveMessageWithAttachments(ctx, otherUser.Id, c.Id, "", "This is mine", nil, []string{attachment.Id})
+		require.ErrorIs(t, err, &chat.AttachmentDoesNotExistError{})
+
+		// Nothing is sent when an attachment is rejected
+		messages, err := chatRepo.GetMessages(ctx, otherUser.Id, c.Id, "", "", 30)
+		require.NoError(t, err)
+		for _, message := range messages {
+			require.NotEqual(t, message.Content, "This is mine")
+		}
+	})
+
+	t.Run("delete message with attachments", func(t *testing.T) {
+		attachment, err := chatRepo.SaveAttachment(ctx, upload)
+		require.NoError(t, err)
+
+		message, _, err := chatRepo.SaveMessageWithAttachments(ctx, testUser.Id, c.Id, "", "", nil, []string{attachment.Id})
+		require.NoError(t, err)
+
+		_, _, err = chatRepo.DeleteMessage(ctx, message.Id)
+		require.NoError(t, err)
+
+		_, err = chatRepo.GetAttachmentById(ctx, attachment.Id)
+		require.ErrorIs(t, err, &chat.AttachmentDoesNotExistError{})
+	})
+}
+
+func TestRepository_SearchMessages(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
+	require.NoError(t, err)
+	otherChat, err := chatRepo.SaveChat(ctx, []string{otherUser.Id})
+	require.NoError(t, err)
+
+	first, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "Lunch at the usual place?")
+	require.NoError(t, err)
+	second, err := chatRepo.SaveMessage(ctx, otherUser.Id, c.Id, "Sure, lunch at noon")
+	require.NoError(t, err)
+	_, err = chatRepo.SaveMessage(ctx, otherUser.Id, otherChat.Id, "Lunch without you")
+	require.NoError(t, err)
+
+	t.Run("search chats of user", func(t *testing.T) {
+		results, err := chatRepo.SearchMessages(ctx, testUser.Id, chat.SearchFilter{Query: "lunch"}, 30)
+		require.NoError(t, err)
+		// Newest first, messages of other chats are not found
+		require.Len(t, results, 2)
+		require.Equal(t, results[0].Message.Id, second.Id)
+		require.Equal(t, results[1].Message.Id, first.Id)
+
+		require.Equal(t, "Sure, lunch at noon", results[0].Snippet)
+		require.Equal(t, []chat.Highlight{{Offset: 6, Length: 5}}, results[0].Highlights)
+	})
+
+	t.Run("search with filters", func(t *testing.T) {
+		results, err := chatRepo.SearchMessages(ctx, testUser.Id, chat.SearchFilter{Query: "lunch", AuthorId: testUser.Id}, 30)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, results[0].Message.Id, first.Id)
+
+		results, err = chatRepo.SearchMessages(ctx, testUser.Id, chat.SearchFilter{Query: "lunch", Before: second.Id}, 30)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, results[0].Message.Id, first.Id)
+
+		results, err = chatRepo.SearchMessages(ctx, testUser.Id, chat.SearchFilter{Query: "lunch", HasAttachment: true}, 30)
+		require.NoError(t, err)
+		require.Empty(t, results)
+
+		results, err = chatRepo.SearchMessages(ctx, testUser.Id, chat.SearchFilter{Query: "lunch -noon"}, 30)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, results[0].Message.Id, first.Id)
+	})
+
+	t.Run("search edited and deleted messages", func(t *testing.T) {
+		_, err := chatRepo.EditMessage(ctx, first.Id, "Dinner at the usual place?")
+		require.NoError(t, err)
+		_, _, err = chatRepo.DeleteMessage(ctx, second.Id)
+		require.NoError(t, err)
+
+		results, err := chatRepo.SearchMessages(ctx, testUser.Id, chat.SearchFilter{Query: "lunch"}, 30)
+		require.NoError(t, err)
+		require.Empty(t, results)
+
+		results, err = chatRepo.SearchMessages(ctx, testUser.Id, chat.SearchFilter{Query: "dinner", ChatId: c.Id}, 30)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+	})
+}
+
+func TestRepository_SaveMessageWithClientId(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id, otherUser.Id})
+	require.NoError(t, err)
+
+	t.Run("replay message", func(t *testing.T) {
+		clientId := uuid.NewString()
+		message, created, err := chatRepo.SaveMessageWithAttachments(ctx, testUser.Id, c.Id, clientId, "This is a test message", nil, nil)
+		require.NoError(t, err)
+		require.True(t, created)
+
+		replayed, created, err := chatRepo.SaveMessageWithAttachments(ctx, testUser.Id, c.Id, clientId, "This is a retry", nil, nil)
+		require.NoError(t, err)
+		require.False(t, created)
+		require.Equal(t, message.Id, replayed.Id)
+		require.Equal(t, "This is a test message", replayed.Content)
+
+		// Client ids only need to be unique per sender
+		other, created, err := chatRepo.SaveMessageWithAttachments(ctx, otherUser.Id, c.Id, clientId, "This is a test message", nil, nil)
+		require.NoError(t, err)
+		require.True(t, created)
+		require.NotEqual(t, message.Id, other.Id)
+	})
+
+	t.Run("replay reply", func(t *testing.T) {
+		root, err := chatRepo.SaveMessage(ctx, testUser.Id, c.Id, "This is the root message")
+		require.NoError(t, err)
+
+		clientId := uuid.NewString()
+		for range 2 {
+			_, _, err := chatRepo.SaveMessageWithAttachments(ctx, otherUser.Id, c.Id, clientId, "This is a reply", &root, nil)
+			require.NoError(t, err)
+		}
+
+		root, err = chatRepo.GetMessageById(ctx, root.Id)
+		require.NoError(t, err)
+		require.Equal(t, 1, root.ThreadReplyCount)
+	})
+
+	t.Run("replay message with attachments", func(t *testing.T) {
+		attachment, err := chatRepo.SaveAttachment(ctx, chat.Attachment{
+			ChatId:      c.Id,
+			UploaderId:  testUser.Id,
+			Filename:    "notes.txt",
+			ContentType: "text/plain; charset=utf-8",
+			Size:        5,
+			Sha256:      "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		})
+		require.NoError(t, err)
+
+		clientId := uuid.NewString()
+		message, _, err := chatRepo.SaveMessageWithAttachments(ctx, testUser.Id, c.Id, clientId, "", nil, []string{attachment.Id})
+		require.NoError(t, err)
+
+		// The attachments are sent already, which is not an error on replay
+		replayed, created, err := chatRepo.SaveMessageWithAttachments(ctx, testUser.Id, c.Id, clientId, "", nil, []string{attachment.Id})
+		require.NoError(t, err)
+		require.False(t, created)
+		require.Equal(t, message.Id, replayed.Id)
+	})
+}
+
+func TestRepository_UpdateChatInfo(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	testUser, err := userRepo.CreateUser(ctx, "test_user", "test@example.org", testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+	require.Equal(t, testUser.Id, *c.CreatedBy)
+
+	name := "Book club"
+	topic := "Chapter 3"
+
+	t.Run("update chat info", func(t *testing.T) {
+		updated, messages, err := chatRepo.UpdateChatInfo(ctx, testUser.Id, c.Id, chat.ChatInfoUpdate{Name: &name, Topic: &topic})
+		require.NoError(t, err)
+		require.Equal(t, name, updated.Name)
+		require.Equal(t, topic, updated.Topic)
+
+		require.Len(t, messages, 2)
+		require.Equal(t, chat.SystemMessage, messages[0].Kind)
+		require.Equal(t, string(chat.ChatRenamedAction), messages[0].Content)
+		require.Equal(t, name, messages[0].Metadata["name"])
+		require.Equal(t, string(chat.TopicChangedAction), messages[1].Content)
+
+		saved, err := chatRepo.GetChatById(ctx, c.Id)
+		require.NoError(t, err)
+		require.Equal(t, name, saved.Name)
+		require.Equal(t, topic, saved.Topic)
+	})
+
+	t.Run("update chat info without changes", func(t *testing.T) {
+		_, messages, err := chatRepo.UpdateChatInfo(ctx, testUser.Id, c.Id, chat.ChatInfoUpdate{Name: &name})
+		require.NoError(t, err)
+		require.Empty(t, messages)
+	})
+
+	t.Run("set and remove avatar", func(t *testing.T) {
+		attachment, err := chatRepo.SaveAttachment(ctx, chat.Attachment{
+			ChatId:      c.Id,
+			UploaderId:  testUser.Id,
+			Filename:    "avatar.png",
+			ContentType: "image/png",
+			Size:        1024,
+			Sha256:      "0f343b0931126a20f133d67c2b018a3b0f343b0931126a20f133d67c2b018a3b",
+		})
+		require.NoError(t, err)
+
+		updated, messages, err := chatRepo.UpdateChatInfo(ctx, testUser.Id, c.Id, chat.ChatInfoUpdate{AvatarId: &attachment.Id})
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Equal(t, attachment.Id, *updated.AvatarId)
+		require.NotEmpty(t, updated.AvatarUrl)
+
+		none := ""
+		updated, messages, err = chatRepo.UpdateChatInfo(ctx, testUser.Id, c.Id, chat.ChatInfoUpdate{AvatarId: &none})
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Nil(t, updated.AvatarId)
+	})
+
+	t.Run("update chat that does not exist", func(t *testing.T) {
+		_, _, err := chatRepo.UpdateChatInfo(ctx, testUser.Id, uuid.NewString(), chat.ChatInfoUpdate{Name: &name})
+		require.ErrorIs(t, err, &chat.ChatDoesNotExistError{})
+	})
+}
+
+func TestRepository_Invites(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+	thirdUser, err := userRepo.CreateUser(ctx, "test_user3", email, testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+
+	t.Run("join chat by invite", func(t *testing.T) {
+		maxUses := 1
+		invite, err := chatRepo.SaveInvite(ctx, c.Id, testUser.Id, "hash_of_single_use_token", nil, &maxUses)
+		require.NoError(t, err)
+		require.False(t, invite.ExpiresAt.Valid)
+
+		chatId, message, err := chatRepo.JoinChatByInvite(ctx, otherUser.Id, "hash_of_single_use_token")
+		require.NoError(t, err)
+		require.Equal(t, c.Id, chatId)
+		require.NotNil(t, message)
+		require.Equal(t, string(chat.MemberJoinedAction), message.Content)
+
+		ok, err := chatRepo.IsMemberOfChatById(ctx, otherUser.Id, c.Id)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		// Members joining again do not use the invite up
+		_, message, err = chatRepo.JoinChatByInvite(ctx, otherUser.Id, "hash_of_single_use_token")
+		require.NoError(t, err)
+		require.Nil(t, message)
+
+		uses, err := chatRepo.GetInviteUses(ctx, c.Id, invite.Id)
+		require.NoError(t, err)
+		require.Len(t, uses, 1)
+		require.Equal(t, otherUser.Id, uses[0].UserId)
+
+		// Used up
+		_, _, err = chatRepo.JoinChatByInvite(ctx, thirdUser.Id, "hash_of_single_use_token")
+		require.ErrorIs(t, err, &chat.InviteDoesNotExistError{})
+	})
+
+	t.Run("join chat by revoked invite", func(t *testing.T) {
+		invite, err := chatRepo.SaveInvite(ctx, c.Id, testUser.Id, "hash_of_revoked_token", nil, nil)
+		require.NoError(t, err)
+
+		err = chatRepo.RevokeInvite(ctx, c.Id, invite.Id, testUser.Id)
+		require.NoError(t, err)
+
+		_, _, err = chatRepo.JoinChatByInvite(ctx, thirdUser.Id, "hash_of_revoked_token")
+		require.ErrorIs(t, err, &chat.InviteDoesNotExistError{})
+
+		invites, err := chatRepo.GetInvites(ctx, c.Id)
+		require.NoError(t, err)
+		require.Len(t, invites, 2)
+		require.Equal(t, invite.Id, invites[0].Id)
+		require.True(t, invites[0].RevokedAt.Valid)
+	})
+
+	t.Run("join chat by expired invite", func(t *testing.T) {
+		expiresIn := -time.Minute
+		_, err := chatRepo.SaveInvite(ctx, c.Id, testUser.Id, "hash_of_expired_token", &expiresIn, nil)
+		require.NoError(t, err)
+
+		_, _, err = chatRepo.JoinChatByInvite(ctx, thirdUser.Id, "hash_of_expired_token")
+		require.ErrorIs(t, err, &chat.InviteDoesNotExistError{})
+	})
+
+	t.Run("revoke invite of another chat", func(t *testing.T) {
+		other, err := chatRepo.SaveChat(ctx, []string{otherUser.Id})
+		require.NoError(t, err)
+		invite, err := chatRepo.SaveInvite(ctx, other.Id, otherUser.Id, "hash_of_other_token", nil, nil)
+		require.NoError(t, err)
+
+		err = chatRepo.RevokeInvite(ctx, c.Id, invite.Id, testUser.Id)
+		require.ErrorIs(t, err, &chat.InviteDoesNotExistError{})
+	})
+}
+
+func TestRepository_PublicChats(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+
+	email := "test@example.org"
+	testUser, err := userRepo.CreateUser(ctx, "test_user", email, testPasswordHash)
+	require.NoError(t, err)
+	otherUser, err := userRepo.CreateUser(ctx, "test_user2", email, testPasswordHash)
+	require.NoError(t, err)
+	thirdUser, err := userRepo.CreateUser(ctx, "test_user3", email, testPasswordHash)
+	require.NoError(t, err)
+
+	name := "Gophers_100%"
+	public := chat.PublicChat
+	publicChat, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+	require.Equal(t, chat.PrivateChat, publicChat.Visibility)
+	publicChat, messages, err := chatRepo.UpdateChatInfo(ctx, testUser.Id, publicChat.Id, chat.ChatInfoUpdate{Name: &name, Visibility: &public})
+	require.NoError(t, err)
+	require.Equal(t, chat.PublicChat, publicChat.Visibility)
+	require.Len(t, messages, 2)
+	require.Equal(t, string(chat.VisibilityChangedAction), messages[1].Content)
+
+	requestName := "Gopher admins"
+	request := chat.RequestChat
+	requestChat, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+	_, _, err = chatRepo.UpdateChatInfo(ctx, testUser.Id, requestChat.Id, chat.ChatInfoUpdate{Name: &requestName, Visibility: &request})
+	require.NoError(t, err)
+
+	privateName := "Gopher secrets"
+	privateChat, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+	_, _, err = chatRepo.UpdateChatInfo(ctx, testUser.Id, privateChat.Id, chat.ChatInfoUpdate{Name: &privateName})
+	require.NoError(t, err)
+
+	t.Run("direct chats stay private", func(t *testing.T) {
+		direct, err := chatRepo.SaveDirectChat(ctx, testUser.Id, otherUser.Id)
+		require.NoError(t, err)
+
+		_, _, err = chatRepo.UpdateChatInfo(ctx, testUser.Id, direct.Id, chat.ChatInfoUpdate{Visibility: &public})
+		require.ErrorIs(t, err, &chat.InvalidVisibilityError{})
+	})
+
+	t.Run("discover chats", func(t *testing.T) {
+		chats, err := chatRepo.DiscoverChats(ctx, otherUser.Id, "gopher", pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Len(t, chats, 2)
+		require.Equal(t, requestChat.Id, chats[0].Id)
+		require.Equal(t, publicChat.Id, chats[1].Id)
+		require.Equal(t, 1, chats[1].MemberCount)
+		require.False(t, chats[1].IsMember)
+
+		// Wildcards in the query are matched literally
+		chats, err = chatRepo.DiscoverChats(ctx, otherUser.Id, "s_1", pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Len(t, chats, 1)
+		require.Equal(t, publicChat.Id, chats[0].Id)
+
+		chats, err = chatRepo.DiscoverChats(ctx, otherUser.Id, "%", pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Len(t, chats, 1)
+
+		chats, err = chatRepo.DiscoverChats(ctx, otherUser.Id, "", pgtype.Timestamp{}, "", 1)
+		require.NoError(t, err)
+		require.Len(t, chats, 1)
+
+		chats, err = chatRepo.DiscoverChats(ctx, otherUser.Id, "", chats[0].CreatedAt, chats[0].Id, 10)
+		require.NoError(t, err)
+		require.Len(t, chats, 1)
+		require.Equal(t, publicChat.Id, chats[0].Id)
+	})
+
+	t.Run("join public chat", func(t *testing.T) {
+		message, err := chatRepo.JoinPublicChat(ctx, otherUser.Id, publicChat.Id)
+		require.NoError(t, err)
+		require.Equal(t, string(chat.MemberJoinedAction), message.Content)
+		require.Equal(t, otherUser.Id, message.UserId)
+
+		_, err = chatRepo.JoinPublicChat(ctx, otherUser.Id, publicChat.Id)
+		require.ErrorIs(t, err, &chat.UserIsAlreadyAMemberError{})
+
+		// Visibility is checked again when joining
+		_, err = chatRepo.JoinPublicChat(ctx, otherUser.Id, requestChat.Id)
+		require.ErrorIs(t, err, &chat.ChatDoesNotExistError{})
+		_, err = chatRepo.JoinPublicChat(ctx, otherUser.Id, privateChat.Id)
+		require.ErrorIs(t, err, &chat.ChatDoesNotExistError{})
+		_, err = chatRepo.SaveJoinRequest(ctx, publicChat.Id, thirdUser.Id)
+		require.ErrorIs(t, err, &chat.ChatDoesNotExistError{})
+
+		chats, err := chatRepo.DiscoverChats(ctx, otherUser.Id, name, pgtype.Timestamp{}, "", 10)
+		require.NoError(t, err)
+		require.Len(t, chats, 1)
+		require.Equal(t, 2, chats[0].MemberCount)
+		require.True(t, chats[0].IsMember)
+	})
+
+	t.Run("approve join request", func(t *testing.T) {
+		joinRequest, err := chatRepo.SaveJoinRequest(ctx, requestChat.Id, otherUser.Id)
+		require.NoError(t, err)
+		require.Equal(t, chat.PendingJoinRequest, joinRequest.Status)
+
+		// Asking again returns the pending request
+		again, err := chatRepo.SaveJoinRequest(ctx, requestChat.Id, otherUser.Id)
+		require.NoError(t, err)
+		require.Equal(t, joinRequest.Id, again.Id)
+
+		requests, err := chatRepo.GetJoinRequests(ctx, requestChat.Id)
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+
+		_, _, err = chatRepo.ApproveJoinRequest(ctx, testUser.Id, publicChat.Id, joinRequest.Id)
+		require.ErrorIs(t, err, &chat.JoinRequestDoesNotExistError{})
+
+		approved, message, err := chatRepo.ApproveJoinRequest(ctx, testUser.Id, requestChat.Id, joinRequest.Id)
+		require.NoError(t, err)
+		require.Equal(t, chat.ApprovedJoinRequest, approved.Status)
+		require.NotNil(t, approved.DecidedBy)
+		require.Equal(t, testUser.Id, *approved.DecidedBy)
+		require.NotNil(t, message)
+		require.Equal(t, string(chat.MemberAddedAction), message.Content)
+
+		ok, err := chatRepo.IsMemberOfChatById(ctx, otherUser.Id, requestChat.Id)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		_, _, err = chatRepo.ApproveJoinRequest(ctx, testUser.Id, requestChat.Id, joinRequest.Id)
+		require.ErrorIs(t, err, &chat.JoinRequestDoesNotExistError{})
+
+		requests, err = chatRepo.GetJoinRequests(ctx, requestChat.Id)
+		require.NoError(t, err)
+		require.Empty(t, requests)
+	})
+
+	t.Run("reject join request", func(t *testing.T) {
+		joinRequest, err := chatRepo.SaveJoinRequest(ctx, requestChat.Id, thirdUser.Id)
+		require.NoError(t, err)
+
+		rejected, err := chatRepo.RejectJoinRequest(ctx, testUser.Id, requestChat.Id, joinRequest.Id)
+		require.NoError(t, err)
+		require.Equal(t, chat.RejectedJoinRequest, rejected.Status)
+
+		_, err = chatRepo.IsMemberOfChatById(ctx, thirdUser.Id, requestChat.Id)
+		require.ErrorIs(t, err, &chat.UserIsNotAMemberError{})
+
+		// Rejected users may ask again
+		again, err := chatRepo.SaveJoinRequest(ctx, requestChat.Id, thirdUser.Id)
+		require.NoError(t, err)
+		require.NotEqual(t, joinRequest.Id, again.Id)
+		require.Equal(t, chat.PendingJoinRequest, again.Status)
+	})
+}
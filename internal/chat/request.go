@@ -1,17 +1,203 @@
 package chat
 
+import "time"
+
 type SendMessageRequest struct {
-	ChatId  string `json:"chat_id"`
-	UserId  string `json:"user_id"`
+	ChatId  string `uri:"chat_id" json:"-"`
+	UserId  string `json:"-"`
 	Content string `json:"content"`
+	// Id of the message replied to, if any
+	ReplyTo string `json:"reply_to"`
+	// Id chosen by the client to make retries safe, also accepted as the
+	// Idempotency-Key header
+	ClientId string `json:"client_id"`
+	// Uploads of the sender to send along with the message
+	AttachmentIds []string `json:"attachment_ids"`
+}
+
+type EditMessageRequest struct {
+	ChatId    string `uri:"chat_id" json:"-"`
+	MessageId string `uri:"message_id" json:"-"`
+	Content   string `json:"content"`
+}
+
+type DeleteMessageRequest struct {
+	ChatId    string        `uri:"chat_id"`
+	MessageId string        `uri:"message_id"`
+	Scope     DeletionScope `form:"scope"`
+}
+
+type GetMessageRevisionsRequest struct {
+	ChatId    string `uri:"chat_id"`
+	MessageId string `uri:"message_id"`
 }
 
 type GetMessagesRequest struct {
-	ChatId       string `uri:"chat_id"`
-	MessageCount int    `uri:"message_count"`
-	Offset       int    `uri:"offset"`
+	ChatId string `uri:"chat_id"`
+	Before string `form:"before"`
+	After  string `form:"after"`
+	Limit  int    `form:"limit"`
 }
 
 type CreateChatRequest struct {
+	// Group when empty
+	Kind    ChatKind `json:"kind"`
 	Members []string `json:"members"`
 }
+
+type GetChatRequest struct {
+	ChatId string `uri:"chat_id"`
+}
+
+type UpdateChatRequest struct {
+	ChatId      string  `uri:"chat_id" json:"-"`
+	Name        *string `json:"name"`
+	Topic       *string `json:"topic"`
+	Description *string `json:"description"`
+	// Id of an image attachment of the chat, empty to remove the avatar
+	AvatarId   *string         `json:"avatar_id"`
+	Visibility *ChatVisibility `json:"visibility"`
+}
+
+type SubscribeRequest struct {
+	ChatId string `uri:"chat_id"`
+}
+
+type StreamEventsRequest struct {
+	ChatId string `uri:"chat_id"`
+	// EventSource can only send the header when reconnecting, the query
+	// parameter allows resuming a fresh connection as well
+	LastEventId      string `header:"Last-Event-ID"`
+	LastEventIdQuery string `form:"last_event_id"`
+}
+
+type UpdateMemberRoleRequest struct {
+	ChatId string `uri:"chat_id" json:"-"`
+	UserId string `uri:"user_id" json:"-"`
+	Role   Role   `json:"role"`
+}
+
+type TransferOwnershipRequest struct {
+	ChatId string `uri:"chat_id" json:"-"`
+	UserId string `json:"user_id"`
+}
+
+type AddMemberRequest struct {
+	ChatId string `uri:"chat_id" json:"-"`
+	UserId string `json:"user_id"`
+}
+
+type RemoveMemberRequest struct {
+	ChatId string `uri:"chat_id"`
+	UserId string `uri:"user_id"`
+}
+
+type LeaveChatRequest struct {
+	ChatId string `uri:"chat_id"`
+}
+
+type GetUserChatsRequest struct {
+	UserId string `uri:"user_id"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+type MarkReadRequest struct {
+	ChatId    string `uri:"chat_id" json:"-"`
+	MessageId string `json:"message_id"`
+}
+
+type GetMessageReadersRequest struct {
+	ChatId    string `uri:"chat_id"`
+	MessageId string `uri:"message_id"`
+}
+
+type ReactionRequest struct {
+	ChatId    string `uri:"chat_id"`
+	MessageId string `uri:"message_id"`
+	Emoji     Emoji  `uri:"emoji"`
+}
+
+type GetThreadRequest struct {
+	ChatId    string `uri:"chat_id"`
+	MessageId string `uri:"message_id"`
+	After     string `form:"after"`
+	Limit     int    `form:"limit"`
+}
+
+type GetUserMentionsRequest struct {
+	UserId string `uri:"user_id"`
+	Before string `form:"before"`
+	Limit  int    `form:"limit"`
+}
+
+type UploadAttachmentRequest struct {
+	ChatId string `uri:"chat_id"`
+}
+
+type GetAttachmentRequest struct {
+	ChatId       string `uri:"chat_id"`
+	AttachmentId string `uri:"attachment_id"`
+}
+
+type SearchMessagesRequest struct {
+	// Only set when searching a single chat
+	ChatId        string    `uri:"chat_id"`
+	Query         string    `form:"q"`
+	AuthorId      string    `form:"author_id"`
+	Since         time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until         time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	HasAttachment bool      `form:"has_attachment"`
+	Before        string    `form:"before"`
+	Limit         int       `form:"limit"`
+}
+
+type CreateInviteRequest struct {
+	ChatId string `uri:"chat_id" json:"-"`
+	// Seconds until the invite expires, it never does when zero
+	ExpiresIn int `json:"expires_in"`
+	// Unlimited when zero
+	MaxUses int `json:"max_uses"`
+}
+
+type GetInvitesRequest struct {
+	ChatId string `uri:"chat_id"`
+}
+
+type RevokeInviteRequest struct {
+	ChatId   string `uri:"chat_id"`
+	InviteId string `uri:"invite_id"`
+}
+
+type GetInviteUsesRequest struct {
+	ChatId   string `uri:"chat_id"`
+	InviteId string `uri:"invite_id"`
+}
+
+type JoinChatRequest struct {
+	Token string `uri:"token"`
+}
+
+type TypingRequest struct {
+	ChatId string `uri:"chat_id"`
+}
+
+type DiscoverChatsRequest struct {
+	// Matched against chat names, every chat that is not private when empty
+	Query  string `form:"q"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+type JoinPublicChatRequest struct {
+	ChatId string `uri:"chat_id"`
+}
+
+type GetJoinRequestsRequest struct {
+	ChatId string `uri:"chat_id"`
+}
+
+type DecideJoinRequestRequest struct {
+	ChatId    string `uri:"chat_id"`
+	RequestId string `uri:"request_id"`
+}
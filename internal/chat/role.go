@@ -0,0 +1,66 @@
+package chat
+
+type Role string
+
+const (
+	OwnerRole  Role = "owner"
+	AdminRole  Role = "admin"
+	MemberRole Role = "member"
+)
+
+type Permission int
+
+const (
+	AddMembersPermission Permission = iota
+	RemoveMembersPermission
+	// Covers every detail of the chat, not only its name
+	RenameChatPermission
+	DeleteOthersMessagesPermission
+	ManageRolesPermission
+	TransferOwnershipPermission
+)
+
+var rolePermissions = map[Role][]Permission{
+	OwnerRole: {
+		AddMembersPermission,
+		RemoveMembersPermission,
+		RenameChatPermission,
+		DeleteOthersMessagesPermission,
+		ManageRolesPermission,
+		TransferOwnershipPermission,
+	},
+	AdminRole: {
+		AddMembersPermission,
+		RemoveMembersPermission,
+		RenameChatPermission,
+		DeleteOthersMessagesPermission,
+	},
+	MemberRole: {},
+}
+
+var roleRanks = map[Role]int{
+	OwnerRole:  3,
+	AdminRole:  2,
+	MemberRole: 1,
+}
+
+func (r Role) IsValid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Outranks reports whether a member with this role may act on a member with
+// the other role, e.g. admins can remove members but not other admins.
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
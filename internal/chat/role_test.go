@@ -0,0 +1,38 @@
+package chat_test
+
+import (
+	"go_chat/internal/chat"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRole_Can(t *testing.T) {
+	t.Run("owner can transfer ownership", func(t *testing.T) {
+		require.True(t, chat.OwnerRole.Can(chat.TransferOwnershipPermission))
+	})
+
+	t.Run("admin can moderate but not manage roles", func(t *testing.T) {
+		require.True(t, chat.AdminRole.Can(chat.RemoveMembersPermission))
+		require.True(t, chat.AdminRole.Can(chat.DeleteOthersMessagesPermission))
+		require.False(t, chat.AdminRole.Can(chat.ManageRolesPermission))
+		require.False(t, chat.AdminRole.Can(chat.TransferOwnershipPermission))
+	})
+
+	t.Run("member cannot moderate", func(t *testing.T) {
+		require.False(t, chat.MemberRole.Can(chat.AddMembersPermission))
+		require.False(t, chat.MemberRole.Can(chat.DeleteOthersMessagesPermission))
+	})
+
+	t.Run("unknown role has no permission", func(t *testing.T) {
+		require.False(t, chat.Role("guest").IsValid())
+		require.False(t, chat.Role("guest").Can(chat.AddMembersPermission))
+	})
+}
+
+func TestRole_Outranks(t *testing.T) {
+	require.True(t, chat.OwnerRole.Outranks(chat.AdminRole))
+	require.True(t, chat.AdminRole.Outranks(chat.MemberRole))
+	require.False(t, chat.AdminRole.Outranks(chat.AdminRole))
+	require.False(t, chat.MemberRole.Outranks(chat.OwnerRole))
+}
@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	maxSearchQueryLength = 256
+
+	// Postgres wraps matches in these, they are stripped from content before
+	// building the headline so they cannot be forged.
+	highlightStart = '\x02'
+	highlightStop  = '\x03'
+)
+
+var headlineOptions = "StartSel=" + string(highlightStart) + ", StopSel=" + string(highlightStop) +
+	", MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// Highlight is a match in a search snippet. Offset and Length are in UTF-16
+// code units, like those of mentions.
+type Highlight struct {
+	Offset int `json:"offset"`
+	Length int `json:"length"`
+}
+
+type SearchResult struct {
+	Message    Message     `json:"message"`
+	Snippet    string      `json:"snippet"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type SearchFilter struct {
+	Query string
+	// Empty to search every chat of the user
+	ChatId   string
+	AuthorId string
+	Since    *time.Time
+	Until    *time.Time
+	// Only messages with attachments
+	HasAttachment bool
+	// Id of the last result of the previous page
+	Before string
+}
+
+// ParseHeadline splits a headline built by Postgres into its plain text and
+// the highlighted matches in it.
+func ParseHeadline(headline string) (string, []Highlight) {
+	var snippet strings.Builder
+	highlights := []Highlight{}
+
+	offset := 0
+	start := -1
+	for _, r := range headline {
+		switch r {
+		case highlightStart:
+			start = offset
+		case highlightStop:
+			if start >= 0 && offset > start {
+				highlights = append(highlights, Highlight{Offset: start, Length: offset - start})
+			}
+			start = -1
+		default:
+			snippet.WriteRune(r)
+			offset += utf16.RuneLen(r)
+		}
+	}
+
+	return snippet.String(), highlights
+}
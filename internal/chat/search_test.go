@@ -0,0 +1,53 @@
+package chat_test
+
+import (
+	"go_chat/internal/chat"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeadline(t *testing.T) {
+	tests := []struct {
+		name       string
+		headline   string
+		snippet    string
+		highlights []chat.Highlight
+	}{
+		{
+			name:       "no match",
+			headline:   "hello there",
+			snippet:    "hello there",
+			highlights: []chat.Highlight{},
+		},
+		{
+			name:     "matches",
+			headline: "\x02hello\x03 there \x02world\x03",
+			snippet:  "hello there world",
+			highlights: []chat.Highlight{
+				{Offset: 0, Length: 5},
+				{Offset: 12, Length: 5},
+			},
+		},
+		{
+			name:       "offsets in utf-16",
+			headline:   "🎉 café \x02party\x03",
+			snippet:    "🎉 café party",
+			highlights: []chat.Highlight{{Offset: 8, Length: 5}},
+		},
+		{
+			name:       "unbalanced markers",
+			headline:   "\x03hello \x02\x03world\x02",
+			snippet:    "hello world",
+			highlights: []chat.Highlight{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snippet, highlights := chat.ParseHeadline(test.headline)
+			require.Equal(t, test.snippet, snippet)
+			require.Equal(t, test.highlights, highlights)
+		})
+	}
+}
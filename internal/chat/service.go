@@ -2,21 +2,77 @@ package chat
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go_chat/internal/blob"
+	"io"
 	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// Client ids are opaque to us, UUIDs fit comfortably
+	maxClientIdLength = 128
+
+	defaultMessagePageSize = 50
+	maxMessagePageSize     = 200
+	defaultChatPageSize    = 20
+	maxChatPageSize        = 100
 )
 
 type ChatService struct {
-	repo *ChatRepository
+	repo              *ChatRepository
+	hub               *Hub
+	publisher         Publisher
+	blobs             blob.BlobStore
+	maxAttachmentSize int64
+	typing            *TypingLimiter
 }
 
-func NewChatService(repo *ChatRepository) *ChatService {
+func NewChatService(repo *ChatRepository, hub *Hub, publisher Publisher, blobs blob.BlobStore, maxAttachmentSize int64) *ChatService {
 	return &ChatService{
-		repo: repo,
+		repo:              repo,
+		hub:               hub,
+		publisher:         publisher,
+		blobs:             blobs,
+		maxAttachmentSize: maxAttachmentSize,
+		typing:            NewTypingLimiter(maxTypingEvents, typingWindow),
 	}
 }
 
-func (s *ChatService) CreateChat(ctx context.Context, chatReq CreateChatRequest) (Chat, error) {
-	chat, err := s.repo.SaveChat(ctx, chatReq.Members)
+// CreateChat creates a chat with the given members, the creator always being
+// one of them. Creating a direct chat with a user returns the existing one if
+// there is any.
+func (s *ChatService) CreateChat(ctx context.Context, creatorId string, chatReq CreateChatRequest) (Chat, error) {
+	members := []string{creatorId}
+	for _, member := range chatReq.Members {
+		if !slices.Contains(members, member) {
+			members = append(members, member)
+		}
+	}
+
+	var chat Chat
+	var err error
+	switch chatReq.Kind {
+	case DirectChat:
+		if len(members) != 2 || uuid.Validate(members[1]) != nil {
+			return Chat{}, &InvalidDirectChatError{}
+		}
+
+		chat, err = s.repo.SaveDirectChat(ctx, members[0], members[1])
+	case GroupChat, "":
+		chat, err = s.repo.SaveChat(ctx, members)
+	default:
+		return Chat{}, &InvalidChatKindError{}
+	}
 	if err != nil {
 		slog.Error("[ChatService-CreateChat]", "Error", err)
 		return Chat{}, err
@@ -25,15 +81,1294 @@ func (s *ChatService) CreateChat(ctx context.Context, chatReq CreateChatRequest)
 	return chat, nil
 }
 
+// GetChat returns the details of a chat the caller is a member of.
+func (s *ChatService) GetChat(ctx context.Context, callerId string, req GetChatRequest) (Chat, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		slog.Error("[ChatService-GetChat]", "Error", err)
+		return Chat{}, err
+	}
+
+	chat, err := s.repo.GetChatById(ctx, req.ChatId)
+	if err != nil {
+		slog.Error("[ChatService-GetChat]", "Error", err)
+		return Chat{}, err
+	}
+
+	return chat, nil
+}
+
+// UpdateChat changes the name, topic, description or avatar of a chat, which
+// members see in the history as system messages.
+func (s *ChatService) UpdateChat(ctx context.Context, callerId string, req UpdateChatRequest) (Chat, error) {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, RenameChatPermission); err != nil {
+		slog.Error("[ChatService-UpdateChat]", "Error", err)
+		return Chat{}, err
+	}
+
+	update := ChatInfoUpdate{
+		Name:        trimmed(req.Name),
+		Topic:       trimmed(req.Topic),
+		Description: trimmed(req.Description),
+		AvatarId:    req.AvatarId,
+		Visibility:  req.Visibility,
+	}
+
+	if !fitsLength(update.Name, maxChatNameLength) ||
+		!fitsLength(update.Topic, maxChatTopicLength) ||
+		!fitsLength(update.Description, maxChatDescriptionLength) {
+		return Chat{}, &InvalidChatInfoError{}
+	}
+
+	if update.Visibility != nil && !update.Visibility.IsValid() {
+		return Chat{}, &InvalidVisibilityError{}
+	}
+
+	if update.AvatarId != nil && *update.AvatarId != "" {
+		if err := s.checkAvatar(ctx, callerId, req.ChatId, *update.AvatarId); err != nil {
+			slog.Error("[ChatService-UpdateChat]", "Error", err)
+			return Chat{}, err
+		}
+	}
+
+	chat, messages, err := s.repo.UpdateChatInfo(ctx, callerId, req.ChatId, update)
+	if err != nil {
+		slog.Error("[ChatService-UpdateChat]", "Error", err)
+		return Chat{}, err
+	}
+
+	for _, message := range messages {
+		s.publish(ctx, NewMessageCreatedEvent(message))
+	}
+
+	return chat, nil
+}
+
+// checkAvatar makes sure an attachment can be the avatar of the chat: an
+// image of the chat that the caller can see.
+func (s *ChatService) checkAvatar(ctx context.Context, callerId string, chatId string, attachmentId string) error {
+	if uuid.Validate(attachmentId) != nil {
+		return &InvalidAvatarError{}
+	}
+
+	attachment, err := s.repo.GetAttachmentById(ctx, attachmentId)
+	if err != nil {
+		if errors.As(err, new(*AttachmentDoesNotExistError)) {
+			return &InvalidAvatarError{}
+		}
+
+		return err
+	}
+
+	if attachment.ChatId != chatId || !attachment.IsInline() ||
+		(attachment.MessageId == nil && attachment.UploaderId != callerId) {
+		return &InvalidAvatarError{}
+	}
+
+	return nil
+}
+
+func trimmed(value *string) *string {
+	if value == nil {
+		return nil
+	}
+
+	trimmed := strings.TrimSpace(*value)
+	return &trimmed
+}
+
+func fitsLength(value *string, maxLength int) bool {
+	return value == nil || utf8.RuneCountInString(*value) <= maxLength
+}
+
 func (s *ChatService) SendMessage(ctx context.Context, req SendMessageRequest) (Message, error) {
 	if ok, err := s.repo.IsMemberOfChatById(ctx, req.UserId, req.ChatId); !ok {
-		slog.Error("[ChatService-CreateChat]", "Error", err)
+		slog.Error("[ChatService-SendMessage]", "Error", err)
+		return Message{}, err
+	}
+
+	if len(req.AttachmentIds) > maxAttachmentsPerMessage {
+		return Message{}, &TooManyAttachmentsError{}
+	}
+
+	if len(req.ClientId) > maxClientIdLength {
+		return Message{}, &InvalidClientIdError{}
+	}
+
+	var parent *Message
+	if req.ReplyTo != "" {
+		replyTo, err := s.getReplyParent(ctx, req)
+		if err != nil {
+			slog.Error("[ChatService-SendMessage]", "Error", err)
+			return Message{}, err
+		}
+		parent = &replyTo
+	}
+
+	var message Message
+	var err error
+	created := true
+	switch {
+	case len(req.AttachmentIds) > 0 || req.ClientId != "":
+		attachmentIds := slices.Clone(req.AttachmentIds)
+		slices.Sort(attachmentIds)
+		attachmentIds = slices.Compact(attachmentIds)
+		for _, attachmentId := range attachmentIds {
+			if uuid.Validate(attachmentId) != nil {
+				return Message{}, &AttachmentDoesNotExistError{}
+			}
+		}
+
+		message, created, err = s.repo.SaveMessageWithAttachments(ctx, req.UserId, req.ChatId, req.ClientId, req.Content, parent, attachmentIds)
+	case parent != nil:
+		message, err = s.repo.SaveReply(ctx, req.UserId, req.Content, *parent)
+	default:
+		message, err = s.repo.SaveMessage(ctx, req.UserId, req.ChatId, req.Content)
+	}
+	if err != nil {
+		slog.Error("[ChatService-SendMessage]", "Error", err)
+		return Message{}, err
+	}
+
+	// A retry of a message sent already gets the message as it is now, and
+	// members are not told about it again
+	if !created {
+		messages := []Message{message}
+		if err := s.repo.AttachDetails(ctx, req.UserId, messages); err != nil {
+			slog.Error("[ChatService-SendMessage]", "Error", err)
+			return Message{}, err
+		}
+
+		return messages[0], nil
+	}
+
+	s.publish(ctx, NewMessageCreatedEvent(message))
+
+	return message, nil
+}
+
+// getReplyParent returns the message the request replies to, which must be
+// part of the same chat.
+func (s *ChatService) getReplyParent(ctx context.Context, req SendMessageRequest) (Message, error) {
+	if uuid.Validate(req.ReplyTo) != nil {
+		return Message{}, &InvalidReplyError{}
+	}
+
+	parent, err := s.getMessageInChat(ctx, req.ChatId, req.ReplyTo)
+	if err != nil {
+		if errors.As(err, new(*MessageDoesNotExistError)) {
+			return Message{}, &InvalidReplyError{}
+		}
+
+		return Message{}, err
+	}
+
+	return parent, nil
+}
+
+// EditMessage lets the author of a text message change its content.
+func (s *ChatService) EditMessage(ctx context.Context, callerId string, req EditMessageRequest) (Message, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		slog.Error("[ChatService-EditMessage]", "Error", err)
+		return Message{}, err
+	}
+
+	message, err := s.getMessageInChat(ctx, req.ChatId, req.MessageId)
+	if err != nil {
+		slog.Error("[ChatService-EditMessage]", "Error", err)
+		return Message{}, err
+	}
+
+	if message.UserId != callerId || message.Kind != TextMessage || message.DeletedAt.Valid {
+		return Message{}, &ActionIsForbiddenError{}
+	}
+
+	if message.Content == req.Content {
+		return message, nil
+	}
+
+	message, err = s.repo.EditMessage(ctx, req.MessageId, req.Content)
+	if err != nil {
+		slog.Error("[ChatService-EditMessage]", "Error", err)
+		return Message{}, err
+	}
+
+	s.publish(ctx, NewMessageEditedEvent(message))
+
+	return message, nil
+}
+
+// DeleteMessage hides the message from the caller, or deletes it for every
+// member if the caller is its author or may delete others' messages. Only
+// text messages can be deleted for everyone.
+func (s *ChatService) DeleteMessage(ctx context.Context, callerId string, req DeleteMessageRequest) error {
+	if req.Scope == "" {
+		req.Scope = DeleteForMe
+	}
+
+	if req.Scope != DeleteForMe && req.Scope != DeleteForEveryone {
+		return &InvalidDeletionScopeError{}
+	}
+
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		slog.Error("[ChatService-DeleteMessage]", "Error", err)
+		return err
+	}
+
+	message, err := s.getMessageInChat(ctx, req.ChatId, req.MessageId)
+	if err != nil {
+		slog.Error("[ChatService-DeleteMessage]", "Error", err)
+		return err
+	}
+
+	if req.Scope == DeleteForMe {
+		if err := s.repo.HideMessage(ctx, callerId, req.MessageId); err != nil {
+			slog.Error("[ChatService-DeleteMessage]", "Error", err)
+			return err
+		}
+
+		s.publish(ctx, NewMessageHiddenEvent(req.ChatId, callerId, req.MessageId))
+		return nil
+	}
+
+	if message.Kind != TextMessage {
+		return &ActionIsForbiddenError{}
+	}
+
+	if message.UserId != callerId {
+		if _, err := s.authorize(ctx, callerId, req.ChatId, DeleteOthersMessagesPermission); err != nil {
+			slog.Error("[ChatService-DeleteMessage]", "Error", err)
+			return err
+		}
+	}
+
+	message, deleted, err := s.repo.DeleteMessage(ctx, req.MessageId)
+	if err != nil {
+		slog.Error("[ChatService-DeleteMessage]", "Error", err)
+		return err
+	}
+
+	if deleted {
+		s.publish(ctx, NewMessageDeletedEvent(message))
+	}
+
+	return nil
+}
+
+func (s *ChatService) GetMessageRevisions(ctx context.Context, callerId string, req GetMessageRevisionsRequest) ([]MessageRevision, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		slog.Error("[ChatService-GetMessageRevisions]", "Error", err)
+		return nil, err
+	}
+
+	if _, err := s.getMessageInChat(ctx, req.ChatId, req.MessageId); err != nil {
+		slog.Error("[ChatService-GetMessageRevisions]", "Error", err)
+		return nil, err
+	}
+
+	return s.repo.GetMessageRevisions(ctx, req.MessageId)
+}
+
+// publish hands the event to the publisher. Whatever it is about is stored
+// already, subscribers that miss the event can catch up through the message
+// history, so failures are only logged.
+func (s *ChatService) publish(ctx context.Context, event Event) {
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		slog.Error("[ChatService-publish]", "Error", err, "EventType", event.Type)
+	}
+}
+
+// GetMessages returns a page of the chat history, oldest first. The page
+// goes back from before, forward from after, or back from the latest message
+// when neither is given. NextCursor is the id to pass as the same parameter
+// to get the following page.
+func (s *ChatService) GetMessages(ctx context.Context, userId string, req GetMessagesRequest) (MessagePage, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, userId, req.ChatId); !ok {
+		slog.Error("[ChatService-GetMessages]", "Error", err)
+		return MessagePage{}, err
+	}
+
+	if req.Before != "" && req.After != "" {
+		return MessagePage{}, &InvalidCursorError{}
+	}
+
+	for _, anchor := range []string{req.Before, req.After} {
+		if anchor == "" {
+			continue
+		}
+
+		if uuid.Validate(anchor) != nil {
+			return MessagePage{}, &InvalidCursorError{}
+		}
+
+		if _, err := s.getMessageInChat(ctx, req.ChatId, anchor); err != nil {
+			if errors.As(err, new(*MessageDoesNotExistError)) {
+				return MessagePage{}, &InvalidCursorError{}
+			}
+
+			slog.Error("[ChatService-GetMessages]", "Error", err)
+			return MessagePage{}, err
+		}
+	}
+
+	limit := clampLimit(req.Limit, defaultMessagePageSize, maxMessagePageSize)
+
+	// One more than asked for tells whether there is a next page
+	messages, err := s.repo.GetMessages(ctx, userId, req.ChatId, req.Before, req.After, limit+1)
+	if err != nil {
+		slog.Error("[ChatService-GetMessages]", "Error", err)
+		return MessagePage{}, err
+	}
+
+	page := MessagePage{Messages: messages}
+	if len(messages) > limit {
+		if req.After != "" {
+			page.Messages = messages[:limit]
+			page.NextCursor = page.Messages[limit-1].Id
+		} else {
+			page.Messages = messages[1:]
+			page.NextCursor = page.Messages[0].Id
+		}
+	}
+
+	if err := s.repo.AttachDetails(ctx, userId, page.Messages); err != nil {
+		slog.Error("[ChatService-GetMessages]", "Error", err)
+		return MessagePage{}, err
+	}
+
+	return page, nil
+}
+
+// GetThread returns the root of the thread the message is part of and a page
+// of its replies, oldest first. NextCursor is the id to pass as after to get
+// the following page.
+func (s *ChatService) GetThread(ctx context.Context, userId string, req GetThreadRequest) (ThreadPage, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, userId, req.ChatId); !ok {
+		slog.Error("[ChatService-GetThread]", "Error", err)
+		return ThreadPage{}, err
+	}
+
+	root, err := s.getMessageInChat(ctx, req.ChatId, req.MessageId)
+	if err != nil {
+		slog.Error("[ChatService-GetThread]", "Error", err)
+		return ThreadPage{}, err
+	}
+
+	if root.ThreadRootId != nil {
+		root, err = s.repo.GetMessageById(ctx, *root.ThreadRootId)
+		if err != nil {
+			slog.Error("[ChatService-GetThread]", "Error", err)
+			return ThreadPage{}, err
+		}
+	}
+
+	if req.After != "" && uuid.Validate(req.After) != nil {
+		return ThreadPage{}, &InvalidCursorError{}
+	}
+
+	limit := clampLimit(req.Limit, defaultMessagePageSize, maxMessagePageSize)
+
+	// One more than asked for tells whether there is a next page
+	messages, err := s.repo.GetThreadMessages(ctx, userId, root.Id, req.After, limit+1)
+	if err != nil {
+		slog.Error("[ChatService-GetThread]", "Error", err)
+		return ThreadPage{}, err
+	}
+
+	page := ThreadPage{Root: root, MessagePage: MessagePage{Messages: messages}}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = page.Messages[limit-1].Id
+	}
+
+	// The root goes along with its replies to fetch details at once
+	withRoot := append([]Message{page.Root}, page.Messages...)
+	if err := s.repo.AttachDetails(ctx, userId, withRoot); err != nil {
+		slog.Error("[ChatService-GetThread]", "Error", err)
+		return ThreadPage{}, err
+	}
+	page.Root, page.Messages = withRoot[0], withRoot[1:]
+
+	return page, nil
+}
+
+func (s *ChatService) Subscribe(ctx context.Context, userId string, chatId string) (*Subscription, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, userId, chatId); !ok {
+		slog.Error("[ChatService-Subscribe]", "Error", err)
+		return nil, err
+	}
+
+	return s.hub.Subscribe(userId, chatId), nil
+}
+
+func (s *ChatService) Unsubscribe(sub *Subscription) {
+	s.hub.Unsubscribe(sub)
+}
+
+// Typing lets the other members of the chat know that the caller is typing.
+// Nothing is stored, subscribers stop showing the caller as typing when the
+// event expires unless another one follows.
+func (s *ChatService) Typing(ctx context.Context, callerId string, req TypingRequest) error {
+	now := time.Now()
+
+	// Checked first so that flooding does not reach the database
+	if !s.typing.Allow(callerId, now) {
+		return &TooManyTypingEventsError{}
+	}
+
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		slog.Error("[ChatService-Typing]", "Error", err)
+		return err
+	}
+
+	s.publish(ctx, NewTypingEvent(req.ChatId, callerId, now.Add(typingTimeout)))
+	return nil
+}
+
+// GetMissedMessages returns the messages a reconnecting subscriber has not
+// seen yet, lastMessageId being the last one it received. Messages the
+// subscriber hid are left out. At most a page of messages is replayed, the
+// subscriber fetches the rest through GetMessages after the last one.
+func (s *ChatService) GetMissedMessages(ctx context.Context, userId string, chatId string, lastMessageId string) ([]Message, error) {
+	if lastMessageId == "" {
+		return nil, nil
+	}
+
+	missed, err := s.repo.GetMessagesAfterId(ctx, userId, chatId, lastMessageId, maxMessagePageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.AttachDetails(ctx, userId, missed); err != nil {
+		return nil, err
+	}
+
+	return missed, nil
+}
+
+// authorize returns the role of the user in the chat if it grants the
+// permission.
+func (s *ChatService) authorize(ctx context.Context, userId string, chatId string, permission Permission) (Role, error) {
+	role, err := s.repo.GetMemberRole(ctx, userId, chatId)
+	if err != nil {
+		return "", err
+	}
+
+	if !role.Can(permission) {
+		return "", &ActionIsForbiddenError{}
+	}
+
+	return role, nil
+}
+
+// UpdateMemberRole promotes a member to admin or demotes an admin back to
+// member. Ownership is only changed through TransferOwnership.
+func (s *ChatService) UpdateMemberRole(ctx context.Context, callerId string, req UpdateMemberRoleRequest) (Role, error) {
+	if req.Role != AdminRole && req.Role != MemberRole {
+		return "", &InvalidRoleError{}
+	}
+
+	callerRole, err := s.authorize(ctx, callerId, req.ChatId, ManageRolesPermission)
+	if err != nil {
+		slog.Error("[ChatService-UpdateMemberRole]", "Error", err)
+		return "", err
+	}
+
+	targetRole, err := s.repo.GetMemberRole(ctx, req.UserId, req.ChatId)
+	if err != nil {
+		slog.Error("[ChatService-UpdateMemberRole]", "Error", err)
+		return "", err
+	}
+
+	if !callerRole.Outranks(targetRole) {
+		return "", &ActionIsForbiddenError{}
+	}
+
+	return s.repo.UpdateMemberRole(ctx, req.ChatId, req.UserId, req.Role)
+}
+
+func (s *ChatService) TransferOwnership(ctx context.Context, callerId string, req TransferOwnershipRequest) error {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, TransferOwnershipPermission); err != nil {
+		slog.Error("[ChatService-TransferOwnership]", "Error", err)
+		return err
+	}
+
+	if callerId == req.UserId {
+		return nil
+	}
+
+	return s.repo.TransferOwnership(ctx, req.ChatId, callerId, req.UserId)
+}
+
+func (s *ChatService) AddMember(ctx context.Context, callerId string, req AddMemberRequest) (Message, error) {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, AddMembersPermission); err != nil {
+		slog.Error("[ChatService-AddMember]", "Error", err)
+		return Message{}, err
+	}
+
+	if err := s.checkMembersCanChange(ctx, req.ChatId); err != nil {
+		slog.Error("[ChatService-AddMember]", "Error", err)
+		return Message{}, err
+	}
+
+	message, err := s.repo.AddMember(ctx, callerId, req.ChatId, req.UserId)
+	if err != nil {
+		slog.Error("[ChatService-AddMember]", "Error", err)
+		return Message{}, err
+	}
+
+	s.publish(ctx, NewMessageCreatedEvent(message))
+
+	return message, nil
+}
+
+// RemoveMember removes another member from the chat, which requires
+// outranking them. Callers removing themselves leave the chat instead.
+func (s *ChatService) RemoveMember(ctx context.Context, callerId string, req RemoveMemberRequest) (Message, error) {
+	if callerId == req.UserId {
+		return s.LeaveChat(ctx, callerId, LeaveChatRequest{ChatId: req.ChatId})
+	}
+
+	callerRole, err := s.authorize(ctx, callerId, req.ChatId, RemoveMembersPermission)
+	if err != nil {
+		slog.Error("[ChatService-RemoveMember]", "Error", err)
+		return Message{}, err
+	}
+
+	targetRole, err := s.repo.GetMemberRole(ctx, req.UserId, req.ChatId)
+	if err != nil {
+		slog.Error("[ChatService-RemoveMember]", "Error", err)
 		return Message{}, err
 	}
 
-	return s.repo.SaveMessage(ctx, req.UserId, req.ChatId, req.Content)
+	if !callerRole.Outranks(targetRole) {
+		return Message{}, &ActionIsForbiddenError{}
+	}
+
+	return s.removeMember(ctx, callerId, req.ChatId, req.UserId)
+}
+
+// LeaveChat removes the caller from the chat. The owner has to transfer
+// ownership first, unless nobody else is left in the chat.
+func (s *ChatService) LeaveChat(ctx context.Context, callerId string, req LeaveChatRequest) (Message, error) {
+	role, err := s.repo.GetMemberRole(ctx, callerId, req.ChatId)
+	if err != nil {
+		slog.Error("[ChatService-LeaveChat]", "Error", err)
+		return Message{}, err
+	}
+
+	if role == OwnerRole {
+		count, err := s.repo.CountMembers(ctx, req.ChatId)
+		if err != nil {
+			slog.Error("[ChatService-LeaveChat]", "Error", err)
+			return Message{}, err
+		}
+
+		if count > 1 {
+			return Message{}, &OwnerCannotLeaveError{}
+		}
+	}
+
+	return s.removeMember(ctx, callerId, req.ChatId, callerId)
 }
 
-func (s *ChatService) GetMessages(ctx context.Context, chatId string, messageCount int, offset int) ([]Message, error) {
-	return s.repo.GetMessages(ctx, chatId, messageCount, offset)
+func (s *ChatService) removeMember(ctx context.Context, callerId string, chatId string, userId string) (Message, error) {
+	if err := s.checkMembersCanChange(ctx, chatId); err != nil {
+		slog.Error("[ChatService-removeMember]", "Error", err)
+		return Message{}, err
+	}
+
+	message, err := s.repo.RemoveMember(ctx, callerId, chatId, userId)
+	if err != nil {
+		slog.Error("[ChatService-removeMember]", "Error", err)
+		return Message{}, err
+	}
+
+	s.publish(ctx, NewMessageCreatedEvent(message))
+	s.publish(ctx, NewMemberRemovedEvent(chatId, userId))
+
+	return message, nil
+}
+
+// CreateInvite creates an invite link to a group chat. Only members who can
+// add members can create one, and the token is only returned this once.
+func (s *ChatService) CreateInvite(ctx context.Context, callerId string, req CreateInviteRequest) (ChatInvite, error) {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, AddMembersPermission); err != nil {
+		slog.Error("[ChatService-CreateInvite]", "Error", err)
+		return ChatInvite{}, err
+	}
+
+	if err := s.checkMembersCanChange(ctx, req.ChatId); err != nil {
+		slog.Error("[ChatService-CreateInvite]", "Error", err)
+		return ChatInvite{}, err
+	}
+
+	var expiresIn *time.Duration
+	if req.ExpiresIn != 0 {
+		lifetime := time.Duration(req.ExpiresIn) * time.Second
+		if req.ExpiresIn < 0 || lifetime > maxInviteLifetime {
+			return ChatInvite{}, &InvalidInviteError{}
+		}
+		expiresIn = &lifetime
+	}
+
+	var maxUses *int
+	if req.MaxUses != 0 {
+		if req.MaxUses < 0 {
+			return ChatInvite{}, &InvalidInviteError{}
+		}
+		maxUses = &req.MaxUses
+	}
+
+	token, tokenHash, err := newInviteToken()
+	if err != nil {
+		slog.Error("[ChatService-CreateInvite]", "Error", err)
+		return ChatInvite{}, err
+	}
+
+	invite, err := s.repo.SaveInvite(ctx, req.ChatId, callerId, tokenHash, expiresIn, maxUses)
+	if err != nil {
+		slog.Error("[ChatService-CreateInvite]", "Error", err)
+		return ChatInvite{}, err
+	}
+
+	invite.Token = token
+	return invite, nil
+}
+
+func (s *ChatService) GetInvites(ctx context.Context, callerId string, req GetInvitesRequest) ([]ChatInvite, error) {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, AddMembersPermission); err != nil {
+		slog.Error("[ChatService-GetInvites]", "Error", err)
+		return nil, err
+	}
+
+	invites, err := s.repo.GetInvites(ctx, req.ChatId)
+	if err != nil {
+		slog.Error("[ChatService-GetInvites]", "Error", err)
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+func (s *ChatService) RevokeInvite(ctx context.Context, callerId string, req RevokeInviteRequest) error {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, AddMembersPermission); err != nil {
+		slog.Error("[ChatService-RevokeInvite]", "Error", err)
+		return err
+	}
+
+	if uuid.Validate(req.InviteId) != nil {
+		return &InviteDoesNotExistError{}
+	}
+
+	return s.repo.RevokeInvite(ctx, req.ChatId, req.InviteId, callerId)
+}
+
+// GetInviteUses returns who joined the chat through an invite.
+func (s *ChatService) GetInviteUses(ctx context.Context, callerId string, req GetInviteUsesRequest) ([]InviteUse, error) {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, AddMembersPermission); err != nil {
+		slog.Error("[ChatService-GetInviteUses]", "Error", err)
+		return nil, err
+	}
+
+	if uuid.Validate(req.InviteId) != nil {
+		return nil, &InviteDoesNotExistError{}
+	}
+
+	uses, err := s.repo.GetInviteUses(ctx, req.ChatId, req.InviteId)
+	if err != nil {
+		slog.Error("[ChatService-GetInviteUses]", "Error", err)
+		return nil, err
+	}
+
+	return uses, nil
+}
+
+// JoinChat adds the caller to the chat of an invite and returns the chat.
+// Members joining again get the chat without using the invite up.
+func (s *ChatService) JoinChat(ctx context.Context, callerId string, req JoinChatRequest) (Chat, error) {
+	chatId, message, err := s.repo.JoinChatByInvite(ctx, callerId, hashInviteToken(req.Token))
+	if err != nil {
+		slog.Error("[ChatService-JoinChat]", "Error", err)
+		return Chat{}, err
+	}
+
+	if message != nil {
+		s.publish(ctx, NewMessageCreatedEvent(*message))
+	}
+
+	chat, err := s.repo.GetChatById(ctx, chatId)
+	if err != nil {
+		slog.Error("[ChatService-JoinChat]", "Error", err)
+		return Chat{}, err
+	}
+
+	return chat, nil
+}
+
+// DiscoverChats returns a page of the chats that are not private, newest
+// first, narrowed down to those whose name contains the query if any.
+func (s *ChatService) DiscoverChats(ctx context.Context, callerId string, req DiscoverChatsRequest) (DiscoverPage, error) {
+	query := strings.TrimSpace(req.Query)
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return DiscoverPage{}, &InvalidSearchQueryError{}
+	}
+
+	var before pgtype.Timestamp
+	var beforeChatId string
+	if req.Cursor != "" {
+		var err error
+		before, beforeChatId, err = decodeChatCursor(req.Cursor)
+		if err != nil {
+			return DiscoverPage{}, err
+		}
+	}
+
+	limit := clampLimit(req.Limit, defaultChatPageSize, maxChatPageSize)
+
+	// One more than asked for tells whether there is a next page
+	chats, err := s.repo.DiscoverChats(ctx, callerId, query, before, beforeChatId, limit+1)
+	if err != nil {
+		slog.Error("[ChatService-DiscoverChats]", "Error", err)
+		return DiscoverPage{}, err
+	}
+
+	page := DiscoverPage{Chats: chats}
+	if len(chats) > limit {
+		page.Chats = chats[:limit]
+		last := page.Chats[limit-1]
+		page.NextCursor = encodeChatCursor(last.CreatedAt, last.Id)
+	}
+
+	return page, nil
+}
+
+// JoinPublicChat adds the caller to a public chat right away and asks the
+// admins of a request chat to let the caller in. Private chats cannot be
+// joined this way and are reported as not existing. Members get the chat.
+func (s *ChatService) JoinPublicChat(ctx context.Context, callerId string, req JoinPublicChatRequest) (JoinResult, error) {
+	if uuid.Validate(req.ChatId) != nil {
+		return JoinResult{}, &ChatDoesNotExistError{}
+	}
+
+	chat, err := s.repo.GetChatById(ctx, req.ChatId)
+	if err != nil {
+		slog.Error("[ChatService-JoinPublicChat]", "Error", err)
+		return JoinResult{}, err
+	}
+
+	if slices.Contains(chat.Members, callerId) {
+		return JoinResult{Chat: &chat}, nil
+	}
+
+	switch chat.Visibility {
+	case PublicChat:
+		message, err := s.repo.JoinPublicChat(ctx, callerId, req.ChatId)
+		if err != nil {
+			slog.Error("[ChatService-JoinPublicChat]", "Error", err)
+			return JoinResult{}, err
+		}
+
+		s.publish(ctx, NewMessageCreatedEvent(message))
+
+		chat, err = s.repo.GetChatById(ctx, req.ChatId)
+		if err != nil {
+			slog.Error("[ChatService-JoinPublicChat]", "Error", err)
+			return JoinResult{}, err
+		}
+
+		return JoinResult{Chat: &chat}, nil
+	case RequestChat:
+		request, err := s.repo.SaveJoinRequest(ctx, req.ChatId, callerId)
+		if err != nil {
+			slog.Error("[ChatService-JoinPublicChat]", "Error", err)
+			return JoinResult{}, err
+		}
+
+		return JoinResult{JoinRequest: &request}, nil
+	default:
+		return JoinResult{}, &ChatDoesNotExistError{}
+	}
+}
+
+// GetJoinRequests returns the pending join requests of the chat to those who
+// can add members.
+func (s *ChatService) GetJoinRequests(ctx context.Context, callerId string, req GetJoinRequestsRequest) ([]JoinRequest, error) {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, AddMembersPermission); err != nil {
+		slog.Error("[ChatService-GetJoinRequests]", "Error", err)
+		return nil, err
+	}
+
+	requests, err := s.repo.GetJoinRequests(ctx, req.ChatId)
+	if err != nil {
+		slog.Error("[ChatService-GetJoinRequests]", "Error", err)
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// ApproveJoinRequest lets the user of a pending join request into the chat.
+func (s *ChatService) ApproveJoinRequest(ctx context.Context, callerId string, req DecideJoinRequestRequest) (JoinRequest, error) {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, AddMembersPermission); err != nil {
+		slog.Error("[ChatService-ApproveJoinRequest]", "Error", err)
+		return JoinRequest{}, err
+	}
+
+	if uuid.Validate(req.RequestId) != nil {
+		return JoinRequest{}, &JoinRequestDoesNotExistError{}
+	}
+
+	request, message, err := s.repo.ApproveJoinRequest(ctx, callerId, req.ChatId, req.RequestId)
+	if err != nil {
+		slog.Error("[ChatService-ApproveJoinRequest]", "Error", err)
+		return JoinRequest{}, err
+	}
+
+	if message != nil {
+		s.publish(ctx, NewMessageCreatedEvent(*message))
+	}
+
+	return request, nil
+}
+
+func (s *ChatService) RejectJoinRequest(ctx context.Context, callerId string, req DecideJoinRequestRequest) (JoinRequest, error) {
+	if _, err := s.authorize(ctx, callerId, req.ChatId, AddMembersPermission); err != nil {
+		slog.Error("[ChatService-RejectJoinRequest]", "Error", err)
+		return JoinRequest{}, err
+	}
+
+	if uuid.Validate(req.RequestId) != nil {
+		return JoinRequest{}, &JoinRequestDoesNotExistError{}
+	}
+
+	return s.repo.RejectJoinRequest(ctx, callerId, req.ChatId, req.RequestId)
+}
+
+// checkMembersCanChange rejects membership changes of direct chats, which
+// would otherwise stop being found by the pair of users they were made for.
+func (s *ChatService) checkMembersCanChange(ctx context.Context, chatId string) error {
+	chat, err := s.repo.GetChatById(ctx, chatId)
+	if err != nil {
+		return err
+	}
+
+	if chat.Kind == DirectChat {
+		return &DirectChatMembersAreFixedError{}
+	}
+
+	return nil
+}
+
+// GetUserChats returns a page of the caller's inbox. Users can only list
+// their own chats.
+func (s *ChatService) GetUserChats(ctx context.Context, callerId string, req GetUserChatsRequest) (ChatPage, error) {
+	if callerId != req.UserId {
+		return ChatPage{}, &ActionIsForbiddenError{}
+	}
+
+	var before pgtype.Timestamp
+	var beforeChatId string
+	if req.Cursor != "" {
+		var err error
+		before, beforeChatId, err = decodeChatCursor(req.Cursor)
+		if err != nil {
+			return ChatPage{}, err
+		}
+	}
+
+	limit := clampLimit(req.Limit, defaultChatPageSize, maxChatPageSize)
+
+	// One more than asked for tells whether there is a next page
+	chats, err := s.repo.GetChatsByUserId(ctx, req.UserId, before, beforeChatId, limit+1)
+	if err != nil {
+		slog.Error("[ChatService-GetUserChats]", "Error", err)
+		return ChatPage{}, err
+	}
+
+	page := ChatPage{Chats: chats}
+	if len(chats) > limit {
+		page.Chats = chats[:limit]
+		last := page.Chats[limit-1]
+		page.NextCursor = encodeChatCursor(last.LastActivityAt, last.Id)
+	}
+
+	return page, nil
+}
+
+// getMessageInChat returns the message if it belongs to the chat.
+func (s *ChatService) getMessageInChat(ctx context.Context, chatId string, messageId string) (Message, error) {
+	message, err := s.repo.GetMessageById(ctx, messageId)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if message.ChatId != chatId {
+		return Message{}, &MessageDoesNotExistError{}
+	}
+
+	return message, nil
+}
+
+// MarkRead advances the caller's read cursor to the message and lets the
+// other members know. Marking an older message as read changes nothing.
+func (s *ChatService) MarkRead(ctx context.Context, callerId string, req MarkReadRequest) error {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		slog.Error("[ChatService-MarkRead]", "Error", err)
+		return err
+	}
+
+	if _, err := s.getMessageInChat(ctx, req.ChatId, req.MessageId); err != nil {
+		slog.Error("[ChatService-MarkRead]", "Error", err)
+		return err
+	}
+
+	receipt, moved, err := s.repo.MarkRead(ctx, callerId, req.ChatId, req.MessageId)
+	if err != nil {
+		slog.Error("[ChatService-MarkRead]", "Error", err)
+		return err
+	}
+
+	if moved {
+		s.publish(ctx, NewMessageReadEvent(receipt))
+	}
+
+	return nil
+}
+
+func (s *ChatService) GetMessageReaders(ctx context.Context, callerId string, req GetMessageReadersRequest) ([]ReadReceipt, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		slog.Error("[ChatService-GetMessageReaders]", "Error", err)
+		return nil, err
+	}
+
+	if _, err := s.getMessageInChat(ctx, req.ChatId, req.MessageId); err != nil {
+		slog.Error("[ChatService-GetMessageReaders]", "Error", err)
+		return nil, err
+	}
+
+	return s.repo.GetMessageReaders(ctx, req.ChatId, req.MessageId)
+}
+
+// AddReaction reacts to a message of the chat on behalf of the caller.
+// Reacting twice with the same emoji changes nothing.
+func (s *ChatService) AddReaction(ctx context.Context, callerId string, req ReactionRequest) error {
+	reaction, err := s.reactionFor(ctx, callerId, req)
+	if err != nil {
+		slog.Error("[ChatService-AddReaction]", "Error", err)
+		return err
+	}
+
+	added, err := s.repo.AddReaction(ctx, reaction)
+	if err != nil {
+		slog.Error("[ChatService-AddReaction]", "Error", err)
+		return err
+	}
+
+	if added {
+		s.publish(ctx, NewReactionEvent(ReactionAddedEvent, req.ChatId, reaction))
+	}
+
+	return nil
+}
+
+func (s *ChatService) RemoveReaction(ctx context.Context, callerId string, req ReactionRequest) error {
+	reaction, err := s.reactionFor(ctx, callerId, req)
+	if err != nil {
+		slog.Error("[ChatService-RemoveReaction]", "Error", err)
+		return err
+	}
+
+	removed, err := s.repo.RemoveReaction(ctx, reaction)
+	if err != nil {
+		slog.Error("[ChatService-RemoveReaction]", "Error", err)
+		return err
+	}
+
+	if removed {
+		s.publish(ctx, NewReactionEvent(ReactionRemovedEvent, req.ChatId, reaction))
+	}
+
+	return nil
+}
+
+// reactionFor checks that the caller can react to the message of the request.
+func (s *ChatService) reactionFor(ctx context.Context, callerId string, req ReactionRequest) (Reaction, error) {
+	if !req.Emoji.IsValid() {
+		return Reaction{}, &InvalidEmojiError{}
+	}
+
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		return Reaction{}, err
+	}
+
+	message, err := s.getMessageInChat(ctx, req.ChatId, req.MessageId)
+	if err != nil {
+		return Reaction{}, err
+	}
+
+	if message.DeletedAt.Valid {
+		return Reaction{}, &ActionIsForbiddenError{}
+	}
+
+	return Reaction{MessageId: req.MessageId, UserId: callerId, Emoji: req.Emoji}, nil
+}
+
+// GetUserMentions returns a page of the messages mentioning the caller, newest
+// first. Users can only list their own mentions.
+func (s *ChatService) GetUserMentions(ctx context.Context, callerId string, req GetUserMentionsRequest) (MessagePage, error) {
+	if callerId != req.UserId {
+		return MessagePage{}, &ActionIsForbiddenError{}
+	}
+
+	if req.Before != "" && uuid.Validate(req.Before) != nil {
+		return MessagePage{}, &InvalidCursorError{}
+	}
+
+	limit := clampLimit(req.Limit, defaultMessagePageSize, maxMessagePageSize)
+
+	// One more than asked for tells whether there is a next page
+	messages, err := s.repo.GetMessagesMentioningUser(ctx, req.UserId, req.Before, limit+1)
+	if err != nil {
+		slog.Error("[ChatService-GetUserMentions]", "Error", err)
+		return MessagePage{}, err
+	}
+
+	page := MessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = page.Messages[limit-1].Id
+	}
+
+	if err := s.repo.AttachDetails(ctx, req.UserId, page.Messages); err != nil {
+		slog.Error("[ChatService-GetUserMentions]", "Error", err)
+		return MessagePage{}, err
+	}
+
+	return page, nil
+}
+
+// SearchMessages searches the messages of every chat the caller is a member
+// of, newest first.
+func (s *ChatService) SearchMessages(ctx context.Context, callerId string, req SearchMessagesRequest) (SearchPage, error) {
+	req.ChatId = ""
+	return s.searchMessages(ctx, callerId, req)
+}
+
+// SearchChatMessages searches the messages of a chat, newest first.
+func (s *ChatService) SearchChatMessages(ctx context.Context, callerId string, req SearchMessagesRequest) (SearchPage, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		slog.Error("[ChatService-SearchChatMessages]", "Error", err)
+		return SearchPage{}, err
+	}
+
+	return s.searchMessages(ctx, callerId, req)
+}
+
+func (s *ChatService) searchMessages(ctx context.Context, callerId string, req SearchMessagesRequest) (SearchPage, error) {
+	query := strings.TrimSpace(req.Query)
+	if query == "" || utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return SearchPage{}, &InvalidSearchQueryError{}
+	}
+
+	if req.AuthorId != "" && uuid.Validate(req.AuthorId) != nil {
+		return SearchPage{}, &InvalidSearchQueryError{}
+	}
+
+	if !req.Since.IsZero() && !req.Until.IsZero() && !req.Since.Before(req.Until) {
+		return SearchPage{}, &InvalidSearchQueryError{}
+	}
+
+	if req.Before != "" && uuid.Validate(req.Before) != nil {
+		return SearchPage{}, &InvalidCursorError{}
+	}
+
+	filter := SearchFilter{
+		Query:         query,
+		ChatId:        req.ChatId,
+		AuthorId:      req.AuthorId,
+		HasAttachment: req.HasAttachment,
+		Before:        req.Before,
+	}
+	// Timestamps are stored in UTC
+	if !req.Since.IsZero() {
+		since := req.Since.UTC()
+		filter.Since = &since
+	}
+	if !req.Until.IsZero() {
+		until := req.Until.UTC()
+		filter.Until = &until
+	}
+
+	limit := clampLimit(req.Limit, defaultMessagePageSize, maxMessagePageSize)
+
+	// One more than asked for tells whether there is a next page
+	results, err := s.repo.SearchMessages(ctx, callerId, filter, limit+1)
+	if err != nil {
+		slog.Error("[ChatService-searchMessages]", "Error", err)
+		return SearchPage{}, err
+	}
+
+	page := SearchPage{Results: results}
+	if len(results) > limit {
+		page.Results = results[:limit]
+		page.NextCursor = page.Results[limit-1].Message.Id
+	}
+
+	messages := make([]Message, len(page.Results))
+	for i, result := range page.Results {
+		messages[i] = result.Message
+	}
+
+	if err := s.repo.AttachDetails(ctx, callerId, messages); err != nil {
+		slog.Error("[ChatService-searchMessages]", "Error", err)
+		return SearchPage{}, err
+	}
+
+	for i := range page.Results {
+		page.Results[i].Message = messages[i]
+	}
+
+	return page, nil
+}
+
+// MaxAttachmentSize is the size in bytes uploads cannot exceed.
+func (s *ChatService) MaxAttachmentSize() int64 {
+	return s.maxAttachmentSize
+}
+
+// UploadAttachment stores a file the caller can then send to the chat with a
+// message. The content type is sniffed rather than taken from the client and
+// content already stored by an earlier upload is not stored again.
+func (s *ChatService) UploadAttachment(ctx context.Context, callerId string, req UploadAttachmentRequest, filename string, content io.ReadSeeker, size int64) (Attachment, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		slog.Error("[ChatService-UploadAttachment]", "Error", err)
+		return Attachment{}, err
+	}
+
+	if size > s.maxAttachmentSize {
+		return Attachment{}, &AttachmentIsTooLargeError{}
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		slog.Error("[ChatService-UploadAttachment]", "Error", err)
+		return Attachment{}, err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	// DetectContentType considers at most the first 512 bytes
+	head := make([]byte, 512)
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		slog.Error("[ChatService-UploadAttachment]", "Error", err)
+		return Attachment{}, err
+	}
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		slog.Error("[ChatService-UploadAttachment]", "Error", err)
+		return Attachment{}, err
+	}
+	contentType := http.DetectContentType(head[:n])
+
+	exists, err := s.blobs.Exists(ctx, sum)
+	if err != nil {
+		slog.Error("[ChatService-UploadAttachment]", "Error", err)
+		return Attachment{}, err
+	}
+
+	if !exists {
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			slog.Error("[ChatService-UploadAttachment]", "Error", err)
+			return Attachment{}, err
+		}
+
+		if err := s.blobs.Put(ctx, sum, content, size, contentType); err != nil {
+			slog.Error("[ChatService-UploadAttachment]", "Error", err)
+			return Attachment{}, err
+		}
+	}
+
+	return s.repo.SaveAttachment(ctx, Attachment{
+		ChatId:      req.ChatId,
+		UploaderId:  callerId,
+		Filename:    sanitizeFilename(filename),
+		ContentType: contentType,
+		Size:        size,
+		Sha256:      sum,
+	})
+}
+
+// GetAttachment returns an attachment of the chat along with its content,
+// which the caller must close. Attachments that are not sent yet are only
+// visible to their uploader.
+func (s *ChatService) GetAttachment(ctx context.Context, callerId string, req GetAttachmentRequest) (Attachment, io.ReadCloser, error) {
+	attachment, err := s.getVisibleAttachment(ctx, callerId, req)
+	if err != nil {
+		slog.Error("[ChatService-GetAttachment]", "Error", err)
+		return Attachment{}, nil, err
+	}
+
+	content, err := s.blobs.Get(ctx, attachment.Sha256)
+	if err != nil {
+		slog.Error("[ChatService-GetAttachment]", "Error", err)
+		return Attachment{}, nil, err
+	}
+
+	return attachment, content, nil
+}
+
+// GetAttachmentThumbnail returns the JPEG thumbnail of an image attachment,
+// which the caller must close. Images without a thumbnail yet are reported
+// as not existing.
+func (s *ChatService) GetAttachmentThumbnail(ctx context.Context, callerId string, req GetAttachmentRequest) (io.ReadCloser, error) {
+	attachment, err := s.getVisibleAttachment(ctx, callerId, req)
+	if err != nil {
+		slog.Error("[ChatService-GetAttachmentThumbnail]", "Error", err)
+		return nil, err
+	}
+
+	if attachment.ThumbnailSha256 == nil {
+		return nil, &AttachmentDoesNotExistError{}
+	}
+
+	content, err := s.blobs.Get(ctx, *attachment.ThumbnailSha256)
+	if err != nil {
+		slog.Error("[ChatService-GetAttachmentThumbnail]", "Error", err)
+		return nil, err
+	}
+
+	return content, nil
+}
+
+func (s *ChatService) getVisibleAttachment(ctx context.Context, callerId string, req GetAttachmentRequest) (Attachment, error) {
+	if ok, err := s.repo.IsMemberOfChatById(ctx, callerId, req.ChatId); !ok {
+		return Attachment{}, err
+	}
+
+	if uuid.Validate(req.AttachmentId) != nil {
+		return Attachment{}, &AttachmentDoesNotExistError{}
+	}
+
+	attachment, err := s.repo.GetAttachmentById(ctx, req.AttachmentId)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	if attachment.ChatId != req.ChatId {
+		return Attachment{}, &AttachmentDoesNotExistError{}
+	}
+
+	// Uploads that are not sent yet are private, unless they are the avatar
+	// of the chat
+	if attachment.MessageId == nil && attachment.UploaderId != callerId {
+		chat, err := s.repo.GetChatById(ctx, req.ChatId)
+		if err != nil {
+			return Attachment{}, err
+		}
+
+		if chat.AvatarId == nil || *chat.AvatarId != attachment.Id {
+			return Attachment{}, &AttachmentDoesNotExistError{}
+		}
+	}
+
+	return attachment, nil
 }
@@ -1,4 +1,5 @@
-INSERT INTO chat_member (chat_id, user_id)
-VALUES ($1, $2)
+INSERT INTO chat_member (chat_id, user_id, role)
+SELECT $1, id, $3 FROM chat_user
+WHERE id = $2 AND NOT deleted
 RETURNING
     user_id
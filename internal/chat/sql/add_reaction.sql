@@ -0,0 +1,11 @@
+INSERT INTO message_reaction (message_id, user_id, emoji) 
+SELECT $1, $2, $3 
+WHERE EXISTS (
+        SELECT 1 FROM message_reaction 
+        WHERE message_id = $1 AND emoji = $3
+    ) 
+    OR (
+        SELECT COUNT(DISTINCT emoji) FROM message_reaction 
+        WHERE message_id = $1
+    ) < $4 
+ON CONFLICT DO NOTHING
@@ -0,0 +1,30 @@
+WITH claimable AS (
+    SELECT id, thumbnail_attempts FROM attachment 
+    WHERE thumbnail_status = 'pending' 
+        AND (thumbnail_claimed_at IS NULL OR thumbnail_claimed_at < NOW() - $2::interval) 
+    ORDER BY created_at 
+    LIMIT $1 
+    FOR UPDATE SKIP LOCKED
+), exhausted AS (
+    UPDATE attachment 
+    SET thumbnail_status = 'failed' 
+    WHERE id IN (SELECT id FROM claimable WHERE thumbnail_attempts >= $3)
+) 
+UPDATE attachment 
+SET thumbnail_attempts = thumbnail_attempts + 1, 
+    thumbnail_claimed_at = NOW() 
+WHERE id IN (SELECT id FROM claimable WHERE thumbnail_attempts < $3) 
+RETURNING 
+    id, 
+    message_id, 
+    chat_id, 
+    uploader_id, 
+    filename, 
+    content_type, 
+    size, 
+    sha256, 
+    created_at, 
+    width, 
+    height, 
+    blurhash, 
+    thumbnail_sha256
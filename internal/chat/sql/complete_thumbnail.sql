@@ -0,0 +1,8 @@
+UPDATE attachment 
+SET width = $2, 
+    height = $3, 
+    blurhash = $4, 
+    thumbnail_sha256 = $5, 
+    thumbnail_status = 'ready', 
+    thumbnail_claimed_at = NULL 
+WHERE id = $1
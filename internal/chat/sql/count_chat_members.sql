@@ -0,0 +1,2 @@
+SELECT COUNT(*) FROM chat_member
+WHERE chat_id = $1
@@ -1,3 +1,3 @@
-INSERT INTO chat (id)
-VALUES (DEFAULT)
-RETURNING id
+INSERT INTO chat (created_by)
+VALUES ($1)
+RETURNING id, created_at
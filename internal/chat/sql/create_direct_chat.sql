@@ -0,0 +1,4 @@
+INSERT INTO chat (kind, direct_key, created_by) 
+VALUES ('direct', $1, $2) 
+ON CONFLICT (direct_key) DO NOTHING 
+RETURNING id
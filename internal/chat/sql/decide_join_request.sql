@@ -0,0 +1,13 @@
+UPDATE chat_join_request 
+SET status = $3, 
+    decided_by = $4, 
+    decided_at = NOW() 
+WHERE id = $1 AND chat_id = $2 AND status = 'pending' 
+RETURNING 
+    id, 
+    chat_id, 
+    user_id, 
+    status, 
+    created_at, 
+    decided_by, 
+    decided_at
@@ -0,0 +1,20 @@
+UPDATE chat_message 
+SET content = '', 
+    metadata = NULL, 
+    deleted_at = NOW() 
+WHERE id = $1 
+    AND deleted_at IS NULL 
+RETURNING 
+    id, 
+    user_id, 
+    chat_id, 
+    kind, 
+    content, 
+    metadata, 
+    created_at, 
+    edited_at, 
+    deleted_at, 
+    reply_to_id, 
+    thread_root_id, 
+    thread_reply_count, 
+    last_reply_at
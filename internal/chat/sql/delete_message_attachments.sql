@@ -0,0 +1,2 @@
+DELETE FROM attachment 
+WHERE message_id = $1
@@ -0,0 +1,2 @@
+DELETE FROM message_mention 
+WHERE message_id = $1
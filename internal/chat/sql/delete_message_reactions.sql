@@ -0,0 +1,2 @@
+DELETE FROM message_reaction 
+WHERE message_id = $1
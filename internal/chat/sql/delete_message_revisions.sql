@@ -0,0 +1,2 @@
+DELETE FROM chat_message_revision 
+WHERE message_id = $1
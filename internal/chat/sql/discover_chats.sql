@@ -0,0 +1,23 @@
+SELECT chat.id, 
+    chat.name, 
+    chat.topic, 
+    chat.description, 
+    chat.visibility, 
+    (
+        SELECT COUNT(*) FROM chat_member member 
+        WHERE member.chat_id = chat.id
+    ) AS member_count, 
+    EXISTS (
+        SELECT 1 FROM chat_member own 
+        WHERE own.chat_id = chat.id AND own.user_id = $1
+    ) AS is_member, 
+    chat.created_at 
+FROM chat 
+WHERE chat.visibility <> 'private' 
+    AND chat.name ILIKE '%' || $2 || '%' 
+    AND (
+        $3::timestamp IS NULL 
+        OR (chat.created_at, chat.id) < ($3, $4::uuid)
+    ) 
+ORDER BY chat.created_at DESC, chat.id DESC 
+LIMIT $5
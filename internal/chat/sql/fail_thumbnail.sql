@@ -0,0 +1,7 @@
+UPDATE attachment 
+SET thumbnail_status = CASE 
+        WHEN $2 AND thumbnail_attempts < $3 THEN 'pending' 
+        ELSE 'failed' 
+    END 
+WHERE id = $1 
+    AND thumbnail_status = 'pending'
@@ -0,0 +1,15 @@
+SELECT 
+    id, 
+    message_id, 
+    chat_id, 
+    uploader_id, 
+    filename, 
+    content_type, 
+    size, 
+    sha256, 
+    created_at, 
+    width, 
+    height, 
+    blurhash, 
+    thumbnail_sha256 FROM attachment 
+WHERE id = $1
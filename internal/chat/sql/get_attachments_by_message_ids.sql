@@ -0,0 +1,16 @@
+SELECT 
+    id, 
+    message_id, 
+    chat_id, 
+    uploader_id, 
+    filename, 
+    content_type, 
+    size, 
+    sha256, 
+    created_at, 
+    width, 
+    height, 
+    blurhash, 
+    thumbnail_sha256 FROM attachment 
+WHERE message_id = ANY($1) 
+ORDER BY message_id, created_at, id
@@ -0,0 +1,2 @@
+SELECT id FROM chat 
+WHERE direct_key = $1
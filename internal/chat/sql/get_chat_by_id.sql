@@ -0,0 +1,15 @@
+SELECT chat.id, 
+    chat.kind, 
+    chat.name, 
+    chat.topic, 
+    chat.description, 
+    chat.avatar_attachment_id, 
+    chat.visibility, 
+    chat.created_by, 
+    chat.created_at, 
+    ARRAY(
+        SELECT member.user_id::text FROM chat_member member 
+        WHERE member.chat_id = chat.id 
+        ORDER BY member.user_id
+    ) AS members FROM chat 
+WHERE chat.id = $1
@@ -0,0 +1,53 @@
+SELECT chat.id, 
+    chat.kind, 
+    chat.name, 
+    chat.topic, 
+    chat.description, 
+    chat.avatar_attachment_id, 
+    chat.visibility, 
+    chat.created_by, 
+    chat.created_at, 
+    ARRAY(
+        SELECT member.user_id::text FROM chat_member member 
+        WHERE member.chat_id = chat.id 
+        ORDER BY member.user_id
+    ) AS members, 
+    last_message.id AS last_message_id, 
+    COALESCE(last_message.created_at, chat.created_at) AS last_activity_at, 
+    (
+        SELECT COUNT(*) FROM chat_message unread 
+        WHERE unread.chat_id = chat.id 
+            AND unread.user_id <> own.user_id 
+            AND unread.deleted_at IS NULL 
+            AND NOT EXISTS (
+                SELECT 1 FROM chat_message_hidden hidden 
+                WHERE hidden.message_id = unread.id AND hidden.user_id = own.user_id
+            ) 
+            AND (
+                own.last_read_message_id IS NULL 
+                OR (unread.created_at, unread.id) > (
+                    SELECT created_at, id FROM chat_message 
+                    WHERE id = own.last_read_message_id
+                )
+            )
+    ) AS unread_count 
+FROM chat_member own 
+JOIN chat ON chat.id = own.chat_id 
+LEFT JOIN LATERAL (
+    SELECT chat_message.id, chat_message.created_at FROM chat_message 
+    WHERE chat_message.chat_id = chat.id 
+        AND chat_message.thread_root_id IS NULL 
+        AND NOT EXISTS (
+            SELECT 1 FROM chat_message_hidden hidden 
+            WHERE hidden.message_id = chat_message.id AND hidden.user_id = own.user_id
+        ) 
+    ORDER BY chat_message.created_at DESC, chat_message.id DESC 
+    LIMIT 1
+) last_message ON true 
+WHERE own.user_id = $1 
+    AND (
+        $2::timestamp IS NULL 
+        OR (COALESCE(last_message.created_at, chat.created_at), chat.id) < ($2, $3::uuid)
+    ) 
+ORDER BY last_activity_at DESC, chat.id DESC 
+LIMIT $4
@@ -0,0 +1,7 @@
+SELECT invite_use.invite_id, 
+    invite_use.user_id, 
+    invite_use.used_at FROM chat_invite_use invite_use 
+JOIN chat_invite invite ON invite.id = invite_use.invite_id 
+WHERE invite_use.invite_id = $1 
+    AND invite.chat_id = $2 
+ORDER BY invite_use.used_at, invite_use.id
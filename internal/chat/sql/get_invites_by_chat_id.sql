@@ -0,0 +1,11 @@
+SELECT 
+    id, 
+    chat_id, 
+    created_by, 
+    expires_at, 
+    max_uses, 
+    use_count, 
+    created_at, 
+    revoked_at FROM chat_invite 
+WHERE chat_id = $1 
+ORDER BY created_at DESC, id DESC
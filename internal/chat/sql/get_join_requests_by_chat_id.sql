@@ -0,0 +1,10 @@
+SELECT 
+    id, 
+    chat_id, 
+    user_id, 
+    status, 
+    created_at, 
+    decided_by, 
+    decided_at FROM chat_join_request 
+WHERE chat_id = $1 AND status = 'pending' 
+ORDER BY created_at, id
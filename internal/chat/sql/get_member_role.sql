@@ -0,0 +1,2 @@
+SELECT role FROM chat_member
+WHERE user_id = $1 AND chat_id = $2
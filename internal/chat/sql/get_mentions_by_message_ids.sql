@@ -0,0 +1,7 @@
+SELECT message_id, 
+    user_id, 
+    start_offset, 
+    length 
+FROM message_mention 
+WHERE message_id = ANY($1) 
+ORDER BY message_id, start_offset
@@ -0,0 +1,11 @@
+SELECT member.user_id, 
+    member.chat_id, 
+    member.last_read_message_id, 
+    member.last_read_at 
+FROM chat_member member 
+JOIN chat_message last_read ON last_read.id = member.last_read_message_id 
+JOIN chat_message target ON target.id = $2 AND target.chat_id = member.chat_id 
+WHERE member.chat_id = $1 
+    AND member.user_id <> target.user_id 
+    AND (last_read.created_at, last_read.id) >= (target.created_at, target.id) 
+ORDER BY member.last_read_at
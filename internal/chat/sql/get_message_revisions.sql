@@ -0,0 +1,6 @@
+SELECT id, 
+    message_id, 
+    content, 
+    created_at FROM chat_message_revision 
+WHERE message_id = $1 
+ORDER BY created_at, id
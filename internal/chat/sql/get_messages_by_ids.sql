@@ -0,0 +1,15 @@
+SELECT 
+    chat_message.id, 
+    chat_message.user_id, 
+    chat_message.chat_id, 
+    chat_message.kind, 
+    chat_message.content, 
+    chat_message.metadata, 
+    chat_message.created_at, 
+    chat_message.edited_at, 
+    chat_message.deleted_at, 
+    chat_message.reply_to_id, 
+    chat_message.thread_root_id, 
+    chat_message.thread_reply_count, 
+    chat_message.last_reply_at FROM chat_message 
+WHERE chat_message.id = ANY($1)
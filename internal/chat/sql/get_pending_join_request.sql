@@ -0,0 +1,9 @@
+SELECT 
+    id, 
+    chat_id, 
+    user_id, 
+    status, 
+    created_at, 
+    decided_by, 
+    decided_at FROM chat_join_request 
+WHERE chat_id = $1 AND user_id = $2 AND status = 'pending'
@@ -0,0 +1,8 @@
+SELECT message_id, 
+    emoji, 
+    COUNT(*) AS count, 
+    COALESCE(BOOL_OR(user_id = NULLIF($2, '')::uuid), false) AS reacted 
+FROM message_reaction 
+WHERE message_id = ANY($1) 
+GROUP BY message_id, emoji 
+ORDER BY message_id, MIN(created_at), emoji
@@ -0,0 +1,8 @@
+SELECT 
+    width, 
+    height, 
+    blurhash, 
+    thumbnail_sha256 FROM attachment 
+WHERE sha256 = $1 
+    AND thumbnail_status = 'ready' 
+LIMIT 1
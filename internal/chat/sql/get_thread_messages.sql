@@ -0,0 +1,28 @@
+SELECT 
+    chat_message.id, 
+    chat_message.user_id, 
+    chat_message.chat_id, 
+    chat_message.kind, 
+    chat_message.content, 
+    chat_message.metadata, 
+    chat_message.created_at, 
+    chat_message.edited_at, 
+    chat_message.deleted_at, 
+    chat_message.reply_to_id, 
+    chat_message.thread_root_id, 
+    chat_message.thread_reply_count, 
+    chat_message.last_reply_at FROM chat_message 
+WHERE chat_message.thread_root_id = $1 
+    AND (
+        NULLIF($2, '') IS NULL 
+        OR (chat_message.created_at, chat_message.id) > (
+            SELECT created_at, id FROM chat_message 
+            WHERE id = NULLIF($2, '')::uuid AND thread_root_id = $1
+        )
+    ) 
+    AND NOT EXISTS (
+        SELECT 1 FROM chat_message_hidden hidden 
+        WHERE hidden.message_id = chat_message.id AND hidden.user_id = $4
+    ) 
+ORDER BY chat_message.created_at, chat_message.id 
+LIMIT $3
@@ -0,0 +1,6 @@
+SELECT id, chat_id FROM chat_invite 
+WHERE token_hash = $1 
+    AND revoked_at IS NULL 
+    AND (expires_at IS NULL OR expires_at > NOW()) 
+    AND (max_uses IS NULL OR use_count < max_uses) 
+FOR UPDATE
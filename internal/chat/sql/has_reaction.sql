@@ -0,0 +1,4 @@
+SELECT EXISTS (
+    SELECT 1 FROM message_reaction 
+    WHERE message_id = $1 AND user_id = $2 AND emoji = $3
+)
@@ -0,0 +1,3 @@
+INSERT INTO chat_message_hidden (message_id, user_id) 
+VALUES ($1, $2) 
+ON CONFLICT DO NOTHING
@@ -0,0 +1,4 @@
+INSERT INTO chat_message_revision (message_id, content, created_at) 
+SELECT id, content, COALESCE(edited_at, created_at) FROM chat_message 
+WHERE id = $1 AND deleted_at IS NULL 
+FOR UPDATE
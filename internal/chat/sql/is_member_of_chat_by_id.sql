@@ -1,2 +1,2 @@
-SELECT * FROM chat_member
+SELECT chat_id, user_id FROM chat_member
 WHERE user_id = $1 AND chat_id = $2
@@ -0,0 +1,20 @@
+UPDATE attachment 
+SET message_id = $1 
+WHERE id = ANY($2) 
+    AND chat_id = $3 
+    AND uploader_id = $4 
+    AND message_id IS NULL 
+RETURNING 
+    id, 
+    message_id, 
+    chat_id, 
+    uploader_id, 
+    filename, 
+    content_type, 
+    size, 
+    sha256, 
+    created_at, 
+    width, 
+    height, 
+    blurhash, 
+    thumbnail_sha256
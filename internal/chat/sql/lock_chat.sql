@@ -0,0 +1,3 @@
+SELECT visibility FROM chat 
+WHERE id = $1 
+FOR UPDATE
@@ -0,0 +1,3 @@
+SELECT deleted_at FROM chat_message 
+WHERE id = $1 
+FOR UPDATE
@@ -0,0 +1,20 @@
+UPDATE chat_member own 
+SET last_read_message_id = target.id, 
+    last_read_at = NOW() 
+FROM chat_message target 
+WHERE own.chat_id = $1 
+    AND own.user_id = $2 
+    AND target.id = $3 
+    AND target.chat_id = own.chat_id 
+    AND (
+        own.last_read_message_id IS NULL 
+        OR (target.created_at, target.id) > (
+            SELECT created_at, id FROM chat_message 
+            WHERE id = own.last_read_message_id
+        )
+    ) 
+RETURNING 
+    own.user_id, 
+    own.chat_id, 
+    own.last_read_message_id, 
+    own.last_read_at
@@ -0,0 +1,4 @@
+DELETE FROM chat_member
+WHERE chat_id = $1 AND user_id = $2
+RETURNING
+    user_id
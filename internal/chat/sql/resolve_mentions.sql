@@ -0,0 +1,6 @@
+SELECT chat_user.username, 
+    chat_user.id FROM chat_user 
+JOIN chat_member member ON member.user_id = chat_user.id 
+WHERE member.chat_id = $1 
+    AND chat_user.username = ANY($2::varchar[]) 
+    AND NOT chat_user.deleted
@@ -0,0 +1,6 @@
+UPDATE chat_invite 
+SET revoked_at = COALESCE(revoked_at, NOW()), 
+    revoked_by = COALESCE(revoked_by, $3) 
+WHERE id = $1 
+    AND chat_id = $2 
+RETURNING id
@@ -0,0 +1,16 @@
+INSERT INTO attachment (chat_id, uploader_id, filename, content_type, size, sha256, thumbnail_status) 
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING 
+    id, 
+    message_id, 
+    chat_id, 
+    uploader_id, 
+    filename, 
+    content_type, 
+    size, 
+    sha256, 
+    created_at, 
+    width, 
+    height, 
+    blurhash, 
+    thumbnail_sha256
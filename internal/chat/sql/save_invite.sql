@@ -0,0 +1,11 @@
+INSERT INTO chat_invite (chat_id, created_by, token_hash, expires_at, max_uses) 
+VALUES ($1, $2, $3, NOW() + $4::interval, $5)
+RETURNING 
+    id, 
+    chat_id, 
+    created_by, 
+    expires_at, 
+    max_uses, 
+    use_count, 
+    created_at, 
+    revoked_at
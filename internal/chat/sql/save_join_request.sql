@@ -0,0 +1,11 @@
+INSERT INTO chat_join_request (chat_id, user_id) 
+VALUES ($1, $2) 
+ON CONFLICT (chat_id, user_id) WHERE status = 'pending' DO NOTHING 
+RETURNING 
+    id, 
+    chat_id, 
+    user_id, 
+    status, 
+    created_at, 
+    decided_by, 
+    decided_at
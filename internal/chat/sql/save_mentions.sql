@@ -0,0 +1,3 @@
+INSERT INTO message_mention (message_id, user_id, start_offset, length) 
+SELECT $1, mention.user_id, mention.start_offset, mention.length 
+FROM UNNEST($2::uuid[], $3::int[], $4::int[]) AS mention (user_id, start_offset, length)
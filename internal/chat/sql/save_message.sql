@@ -1,8 +1,17 @@
-INSERT INTO chat_message (user_id, chat_id, content) 
-VALUES ($1, $2, $3)
+INSERT INTO chat_message (user_id, chat_id, kind, content, metadata, client_id) 
+VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+ON CONFLICT (chat_id, user_id, client_id) DO NOTHING
 RETURNING 
     id, 
     user_id, 
     chat_id, 
+    kind, 
     content, 
-    created_at
+    metadata, 
+    created_at, 
+    edited_at, 
+    deleted_at, 
+    reply_to_id, 
+    thread_root_id, 
+    thread_reply_count, 
+    last_reply_at
@@ -0,0 +1,17 @@
+INSERT INTO chat_message (user_id, chat_id, kind, content, reply_to_id, thread_root_id, client_id) 
+VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+ON CONFLICT (chat_id, user_id, client_id) DO NOTHING
+RETURNING 
+    id, 
+    user_id, 
+    chat_id, 
+    kind, 
+    content, 
+    metadata, 
+    created_at, 
+    edited_at, 
+    deleted_at, 
+    reply_to_id, 
+    thread_root_id, 
+    thread_reply_count, 
+    last_reply_at
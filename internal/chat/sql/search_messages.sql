@@ -0,0 +1,44 @@
+SELECT 
+    chat_message.id, 
+    chat_message.user_id, 
+    chat_message.chat_id, 
+    chat_message.kind, 
+    chat_message.content, 
+    chat_message.metadata, 
+    chat_message.created_at, 
+    chat_message.edited_at, 
+    chat_message.deleted_at, 
+    chat_message.reply_to_id, 
+    chat_message.thread_root_id, 
+    chat_message.thread_reply_count, 
+    chat_message.last_reply_at, 
+    ts_headline('simple', translate(chat_message.content, E'\x02\x03', ''), query, $10) FROM chat_message 
+JOIN chat_member member ON member.chat_id = chat_message.chat_id AND member.user_id = $1 
+CROSS JOIN websearch_to_tsquery('simple', $2) query 
+WHERE chat_message.content_tsv @@ query 
+    AND chat_message.kind = 'text' 
+    AND chat_message.deleted_at IS NULL 
+    AND (NULLIF($3, '') IS NULL OR chat_message.chat_id = NULLIF($3, '')::uuid) 
+    AND (NULLIF($4, '') IS NULL OR chat_message.user_id = NULLIF($4, '')::uuid) 
+    AND ($5::timestamp IS NULL OR chat_message.created_at >= $5) 
+    AND ($6::timestamp IS NULL OR chat_message.created_at < $6) 
+    AND (
+        NOT $7 
+        OR EXISTS (
+            SELECT 1 FROM attachment 
+            WHERE attachment.message_id = chat_message.id
+        )
+    ) 
+    AND NOT EXISTS (
+        SELECT 1 FROM chat_message_hidden hidden 
+        WHERE hidden.message_id = chat_message.id AND hidden.user_id = $1
+    ) 
+    AND (
+        NULLIF($8, '') IS NULL 
+        OR (chat_message.created_at, chat_message.id) < (
+            SELECT created_at, id FROM chat_message 
+            WHERE id = NULLIF($8, '')::uuid
+        )
+    ) 
+ORDER BY chat_message.created_at DESC, chat_message.id DESC 
+LIMIT $9
@@ -0,0 +1,7 @@
+UPDATE chat 
+SET name = $2, 
+    topic = $3, 
+    description = $4, 
+    avatar_attachment_id = $5, 
+    visibility = $6 
+WHERE id = $1
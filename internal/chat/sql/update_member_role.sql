@@ -0,0 +1,5 @@
+UPDATE chat_member 
+SET role = $3 
+WHERE chat_id = $1 AND user_id = $2
+RETURNING
+    role
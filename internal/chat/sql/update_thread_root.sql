@@ -0,0 +1,4 @@
+UPDATE chat_message 
+SET thread_reply_count = thread_reply_count + 1, 
+    last_reply_at = GREATEST(last_reply_at, $2) 
+WHERE id = $1
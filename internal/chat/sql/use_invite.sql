@@ -0,0 +1,7 @@
+WITH invite_use AS (
+    INSERT INTO chat_invite_use (invite_id, user_id) 
+    VALUES ($1, $2)
+)
+UPDATE chat_invite 
+SET use_count = use_count + 1 
+WHERE id = $1
@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const keepAlivePeriod = 30 * time.Second
+
+// serveEventStream replays the missed messages and then writes the events of
+// the subscription as Server-Sent Events until the client goes away.
+func serveEventStream(ctx *gin.Context, sub *Subscription, missed []Message) {
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	// Disables response buffering on nginx style proxies
+	ctx.Header("X-Accel-Buffering", "no")
+
+	// The subscription is opened before the replay is read, so anything
+	// saved in between shows up in both and must only be sent once.
+	replayed := make(map[string]struct{}, len(missed))
+	for _, message := range missed {
+		replayed[message.Id] = struct{}{}
+		renderEvent(ctx, NewMessageCreatedEvent(message))
+	}
+	ctx.Writer.Flush()
+
+	ticker := time.NewTicker(keepAlivePeriod)
+	defer ticker.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+
+			if _, ok := replayed[event.Id]; ok && event.Type == MessageCreatedEvent {
+				return true
+			}
+
+			renderEvent(ctx, event)
+			return true
+		case <-ticker.C:
+			// Comment lines are ignored by clients but keep proxies from
+			// closing an idle connection.
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func renderEvent(ctx *gin.Context, event Event) {
+	// Clients resume from the last id they saw, which must only move
+	// forward with new messages and not with events about older ones.
+	var id string
+	if event.Type == MessageCreatedEvent {
+		id = event.Id
+	}
+
+	ctx.Render(-1, sse.Event{
+		Id:    id,
+		Event: string(event.Type),
+		Data:  event,
+	})
+}
@@ -0,0 +1,241 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go_chat/internal/blob"
+	"go_chat/internal/blurhash"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	thumbnailPending = "pending"
+
+	// Thumbnails fit in a square of this many pixels
+	thumbnailSize    = 320
+	thumbnailQuality = 80
+	// Images are scaled down to about this size before computing a blurhash
+	blurhashSize = 32
+	// Larger images are not decoded to keep memory use bounded
+	maxImagePixels = 50_000_000
+
+	thumbnailBatchSize    = 8
+	thumbnailPollInterval = 2 * time.Second
+	// Claims of a worker that crashed expire after this long
+	thumbnailLease       = 5 * time.Minute
+	maxThumbnailAttempts = 3
+)
+
+// Types the thumbnail worker can decode. GIFs get a thumbnail of their first
+// frame.
+var thumbnailContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// Thumbnails are always JPEG, transparent images are put on white.
+const thumbnailContentType = "image/jpeg"
+
+type Thumbnail struct {
+	Width    int
+	Height   int
+	Blurhash string
+	// Key of the thumbnail in the blob store
+	Sha256 string
+}
+
+// ThumbnailWorker generates thumbnails of uploaded images in the background.
+// Jobs are kept in the attachment table, so any number of workers may run and
+// a restarted worker picks up where the previous one stopped.
+type ThumbnailWorker struct {
+	repo  *ChatRepository
+	blobs blob.BlobStore
+}
+
+func NewThumbnailWorker(repo *ChatRepository, blobs blob.BlobStore) *ThumbnailWorker {
+	return &ThumbnailWorker{
+		repo:  repo,
+		blobs: blobs,
+	}
+}
+
+// Run processes pending thumbnails until the context is cancelled.
+func (w *ThumbnailWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(thumbnailPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while there is a backlog
+		for {
+			processed, err := w.ProcessPending(ctx)
+			if err != nil || processed < thumbnailBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ProcessPending claims a batch of pending thumbnails and processes it,
+// returning how many were claimed.
+func (w *ThumbnailWorker) ProcessPending(ctx context.Context) (int, error) {
+	attachments, err := w.repo.ClaimThumbnailJobs(ctx, thumbnailBatchSize, thumbnailLease, maxThumbnailAttempts)
+	if err != nil {
+		slog.Error("[ThumbnailWorker-ProcessPending]", "Error", err)
+		return 0, err
+	}
+
+	for _, attachment := range attachments {
+		if ctx.Err() != nil {
+			// Left for the next worker once the claims expire
+			return len(attachments), ctx.Err()
+		}
+
+		w.process(ctx, attachment)
+	}
+
+	return len(attachments), nil
+}
+
+func (w *ThumbnailWorker) process(ctx context.Context, attachment Attachment) {
+	thumbnail, err := w.thumbnail(ctx, attachment)
+	if err != nil {
+		slog.Error("[ThumbnailWorker-process]", "AttachmentId", attachment.Id, "Error", err)
+
+		// Images that cannot be decoded will not decode next time either
+		var imageErr *imageError
+		retry := !errors.As(err, &imageErr)
+		if err := w.repo.FailThumbnail(ctx, attachment.Id, retry, maxThumbnailAttempts); err != nil {
+			slog.Error("[ThumbnailWorker-process]", "AttachmentId", attachment.Id, "Error", err)
+		}
+		return
+	}
+
+	if err := w.repo.CompleteThumbnail(ctx, attachment.Id, thumbnail); err != nil {
+		slog.Error("[ThumbnailWorker-process]", "AttachmentId", attachment.Id, "Error", err)
+	}
+}
+
+func (w *ThumbnailWorker) thumbnail(ctx context.Context, attachment Attachment) (Thumbnail, error) {
+	// Uploads of the same content share their thumbnail
+	thumbnail, ok, err := w.repo.GetReadyThumbnail(ctx, attachment.Sha256)
+	if err != nil || ok {
+		return thumbnail, err
+	}
+
+	content, err := w.blobs.Get(ctx, attachment.Sha256)
+	if err != nil {
+		return Thumbnail{}, err
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return Thumbnail{}, err
+	}
+
+	encoded, thumbnail, err := makeThumbnail(data)
+	if err != nil {
+		return Thumbnail{}, err
+	}
+
+	// Thumbnails are content addressed as well, so storing one again after
+	// a crash overwrites it with the same bytes.
+	sum := sha256.Sum256(encoded)
+	thumbnail.Sha256 = hex.EncodeToString(sum[:])
+
+	err = w.blobs.Put(ctx, thumbnail.Sha256, bytes.NewReader(encoded), int64(len(encoded)), thumbnailContentType)
+	if err != nil {
+		return Thumbnail{}, err
+	}
+
+	return thumbnail, nil
+}
+
+// imageError reports content that cannot be turned into a thumbnail.
+type imageError struct {
+	err error
+}
+
+func (e *imageError) Error() string {
+	return "Image cannot be thumbnailed: " + e.err.Error()
+}
+
+func (e *imageError) Unwrap() error {
+	return e.err
+}
+
+// makeThumbnail decodes an image and returns a JPEG thumbnail of it along
+// with its dimensions and blurhash.
+func makeThumbnail(data []byte) ([]byte, Thumbnail, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, Thumbnail{}, &imageError{err}
+	}
+
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return nil, Thumbnail{}, &imageError{errors.New("image dimensions out of range")}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, Thumbnail{}, &imageError{err}
+	}
+
+	thumbnail := scaleToFit(img, thumbnailSize)
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, thumbnail, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, Thumbnail{}, err
+	}
+
+	xComponents, yComponents := 4, 3
+	if config.Height > config.Width {
+		xComponents, yComponents = 3, 4
+	}
+
+	return encoded.Bytes(), Thumbnail{
+		Width:    config.Width,
+		Height:   config.Height,
+		Blurhash: blurhash.Encode(scaleToFit(thumbnail, blurhashSize), xComponents, yComponents),
+	}, nil
+}
+
+// scaleToFit scales an image down to fit in a square of size pixels, keeping
+// its aspect ratio, on a white background. Smaller images are not scaled up.
+func scaleToFit(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	return dst
+}
@@ -0,0 +1,148 @@
+package chat_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"go_chat/internal/blob"
+	"go_chat/internal/chat"
+	"go_chat/internal/user"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnailWorker(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	chatRepo := chat.NewChatRepository(testDb.Pool)
+	userRepo := user.NewUserRepository(testDb.Pool)
+	blobs := blob.NewLocalStore(t.TempDir())
+	worker := chat.NewThumbnailWorker(chatRepo, blobs)
+
+	testUser, err := userRepo.CreateUser(ctx, "test_user", "test@example.org", testPasswordHash)
+	require.NoError(t, err)
+
+	c, err := chatRepo.SaveChat(ctx, []string{testUser.Id})
+	require.NoError(t, err)
+
+	upload := func(t *testing.T, content []byte, contentType string) chat.Attachment {
+		sum := sha256.Sum256(content)
+		key := hex.EncodeToString(sum[:])
+		require.NoError(t, blobs.Put(ctx, key, bytes.NewReader(content), int64(len(content)), contentType))
+
+		attachment, err := chatRepo.SaveAttachment(ctx, chat.Attachment{
+			ChatId:      c.Id,
+			UploaderId:  testUser.Id,
+			Filename:    "file",
+			ContentType: contentType,
+			Size:        int64(len(content)),
+			Sha256:      key,
+		})
+		require.NoError(t, err)
+		return attachment
+	}
+
+	t.Run("generate thumbnail", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 800, 400))
+		for x := range 800 {
+			for y := range 400 {
+				img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+			}
+		}
+		var content bytes.Buffer
+		require.NoError(t, png.Encode(&content, img))
+
+		attachment := upload(t, content.Bytes(), "image/png")
+		require.Empty(t, attachment.ThumbnailUrl)
+
+		processed, err := worker.ProcessPending(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, processed)
+
+		attachment, err = chatRepo.GetAttachmentById(ctx, attachment.Id)
+		require.NoError(t, err)
+		require.Equal(t, 800, *attachment.Width)
+		require.Equal(t, 400, *attachment.Height)
+		require.NotEmpty(t, *attachment.Blurhash)
+		require.NotEmpty(t, attachment.ThumbnailUrl)
+
+		thumbnail, err := blobs.Get(ctx, *attachment.ThumbnailSha256)
+		require.NoError(t, err)
+		defer thumbnail.Close()
+
+		config, format, err := image.DecodeConfig(thumbnail)
+		require.NoError(t, err)
+		require.Equal(t, "jpeg", format)
+		require.Equal(t, 320, config.Width)
+		require.Equal(t, 160, config.Height)
+
+		// Done jobs are not claimed again
+		processed, err = worker.ProcessPending(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, processed)
+
+		// Uploads of the same image share the thumbnail
+		again := upload(t, content.Bytes(), "image/png")
+		_, err = worker.ProcessPending(ctx)
+		require.NoError(t, err)
+
+		again, err = chatRepo.GetAttachmentById(ctx, again.Id)
+		require.NoError(t, err)
+		require.Equal(t, *attachment.ThumbnailSha256, *again.ThumbnailSha256)
+	})
+
+	t.Run("skip attachments that are not images", func(t *testing.T) {
+		upload(t, []byte("Just some text"), "text/plain; charset=utf-8")
+
+		processed, err := worker.ProcessPending(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, processed)
+	})
+
+	t.Run("give up on broken images", func(t *testing.T) {
+		attachment := upload(t, []byte("\x89PNG\r\n\x1a\nnot really"), "image/png")
+
+		processed, err := worker.ProcessPending(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, processed)
+
+		// Failed for good, as decoding would fail again
+		processed, err = worker.ProcessPending(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, processed)
+
+		attachment, err = chatRepo.GetAttachmentById(ctx, attachment.Id)
+		require.NoError(t, err)
+		require.Nil(t, attachment.Width)
+		require.Empty(t, attachment.ThumbnailUrl)
+	})
+
+	t.Run("give up on jobs that keep crashing workers", func(t *testing.T) {
+		attachment := upload(t, []byte("\x89PNG\r\n\x1a\nnever processed"), "image/png")
+
+		// Claims that are never completed expire right away without a lease
+		for range 3 {
+			claimed, err := chatRepo.ClaimThumbnailJobs(ctx, 8, 0, 3)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+			require.Equal(t, attachment.Id, claimed[0].Id)
+		}
+
+		claimed, err := chatRepo.ClaimThumbnailJobs(ctx, 8, 0, 3)
+		require.NoError(t, err)
+		require.Empty(t, claimed)
+
+		// Failed rather than left pending, so more attempts do not bring it back
+		claimed, err = chatRepo.ClaimThumbnailJobs(ctx, 8, 0, 10)
+		require.NoError(t, err)
+		require.Empty(t, claimed)
+	})
+}
@@ -0,0 +1,75 @@
+package chat
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Each typing event shows its user as typing for this long, clients keep
+	// sending them while the user types
+	typingTimeout = 5 * time.Second
+	// Typing events a user may send per window, across all chats
+	maxTypingEvents = 10
+	typingWindow    = 10 * time.Second
+)
+
+// Typing is the payload of a typing event.
+type Typing struct {
+	ChatId    string    `json:"chat_id"`
+	UserId    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type typingCount struct {
+	start time.Time
+	count int
+}
+
+// TypingLimiter counts the typing events of each user in fixed windows. It
+// only knows about the events sent to this instance.
+type TypingLimiter struct {
+	mu        sync.Mutex
+	max       int
+	window    time.Duration
+	counts    map[string]*typingCount
+	lastSweep time.Time
+}
+
+func NewTypingLimiter(max int, window time.Duration) *TypingLimiter {
+	return &TypingLimiter{
+		max:    max,
+		window: window,
+		counts: make(map[string]*typingCount),
+	}
+}
+
+// Allow records a typing event of the user at now and tells whether it stays
+// within the limit.
+func (l *TypingLimiter) Allow(userId string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Users who stopped typing are forgotten once their window is over
+	if now.Sub(l.lastSweep) >= l.window {
+		for id, c := range l.counts {
+			if now.Sub(c.start) >= l.window {
+				delete(l.counts, id)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.counts[userId]
+	if !ok || now.Sub(c.start) >= l.window {
+		l.counts[userId] = &typingCount{start: now, count: 1}
+		return true
+	}
+
+	if c.count >= l.max {
+		return false
+	}
+
+	c.count++
+	return true
+}
@@ -0,0 +1,33 @@
+package chat_test
+
+import (
+	"go_chat/internal/chat"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypingLimiter_Allow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("limit events per window", func(t *testing.T) {
+		limiter := chat.NewTypingLimiter(3, 10*time.Second)
+
+		for i := range 3 {
+			require.True(t, limiter.Allow("user", start.Add(time.Duration(i)*time.Second)))
+		}
+		require.False(t, limiter.Allow("user", start.Add(5*time.Second)))
+
+		// Other users have their own count
+		require.True(t, limiter.Allow("other_user", start.Add(5*time.Second)))
+	})
+
+	t.Run("allow events again in the next window", func(t *testing.T) {
+		limiter := chat.NewTypingLimiter(1, 10*time.Second)
+
+		require.True(t, limiter.Allow("user", start))
+		require.False(t, limiter.Allow("user", start.Add(9*time.Second)))
+		require.True(t, limiter.Allow("user", start.Add(10*time.Second)))
+	})
+}
@@ -0,0 +1,88 @@
+package chat
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// ClientEvent is what clients send over the connection, only TypingEvent
+// for now.
+type ClientEvent struct {
+	Type EventType `json:"type"`
+}
+
+// serveWebSocket writes the events of the subscription to the connection
+// until either side goes away, handing the events the client sends to
+// receive.
+func serveWebSocket(conn *websocket.Conn, sub *Subscription, receive func(ClientEvent)) {
+	done := make(chan struct{})
+
+	// The connection also has to be read for control frames to be processed
+	go func() {
+		defer close(done)
+
+		conn.SetReadLimit(maxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+					slog.Error("[ChatHandler-serveWebSocket]", "Error", err)
+				}
+				return
+			}
+
+			// Anything that is not a client event is ignored
+			var event ClientEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				continue
+			}
+			receive(event)
+		}
+	}()
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := conn.WriteJSON(event); err != nil {
+				slog.Error("[ChatHandler-serveWebSocket]", "Error", err)
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -4,14 +4,30 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port            string
-	Hostname        string
-	DbConnectionUrl string
+	Port             string
+	Hostname         string
+	DbConnectionUrl  string
+	ChatEventChannel string
+	JwtSecret        string
+	AccessTokenTTL   time.Duration
+	RefreshTokenTTL  time.Duration
+	// Either local or s3
+	BlobStore         string
+	BlobDir           string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKey       string
+	S3SecretKey       string
+	S3UseSSL          bool
+	MaxAttachmentSize int64
 }
 
 func init() {
@@ -31,9 +47,74 @@ func GetConfig() *Config {
 	baseConnUrl := fmt.Sprintf("postgres://%v:%v@%v:%v/", DbUsername, DbPassword, DbHostname, DbPort)
 	connectionUrl := baseConnUrl + DbNameUser
 
+	chatEventChannel := os.Getenv("CHAT_EVENT_CHANNEL")
+	if chatEventChannel == "" {
+		chatEventChannel = "chat_events"
+	}
+
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		log.Fatal("JWT_SECRET must be set")
+	}
+
+	blobStore := os.Getenv("BLOB_STORE")
+	if blobStore == "" {
+		blobStore = "local"
+	}
+	if blobStore != "local" && blobStore != "s3" {
+		log.Fatal("BLOB_STORE must be either local or s3")
+	}
+
+	blobDir := os.Getenv("BLOB_DIR")
+	if blobDir == "" {
+		blobDir = "data/blobs"
+	}
+
 	return &Config{
-		Port:            os.Getenv("PORT"),
-		Hostname:        os.Getenv("HOSTNAME"),
-		DbConnectionUrl: connectionUrl,
+		Port:             os.Getenv("PORT"),
+		Hostname:         os.Getenv("HOSTNAME"),
+		DbConnectionUrl:  connectionUrl,
+		ChatEventChannel: chatEventChannel,
+		JwtSecret:        jwtSecret,
+		AccessTokenTTL:   getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:  getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		BlobStore:        blobStore,
+		BlobDir:          blobDir,
+		S3Endpoint:       os.Getenv("S3_ENDPOINT"),
+		S3Region:         os.Getenv("S3_REGION"),
+		S3Bucket:         os.Getenv("S3_BUCKET"),
+		S3AccessKey:      os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:      os.Getenv("S3_SECRET_KEY"),
+		S3UseSSL:         os.Getenv("S3_USE_SSL") == "true",
+		// 25 MiB
+		MaxAttachmentSize: getInt("MAX_ATTACHMENT_SIZE", 25<<20),
+	}
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%s must be a duration such as 15m: %v", key, err)
 	}
+
+	return duration
+}
+
+func getInt(key string, fallback int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		log.Fatalf("%s must be a positive integer: %v", key, value)
+	}
+
+	return n
 }
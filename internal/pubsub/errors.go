@@ -0,0 +1,7 @@
+package pubsub
+
+type PayloadTooLargeError struct{}
+
+func (e *PayloadTooLargeError) Error() string {
+	return "Payload is too large to be sent as a notification"
+}
@@ -0,0 +1,128 @@
+package pubsub
+
+import (
+	"context"
+	_ "embed"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed sql/notify.sql
+var notifyQuery string
+
+// Postgres rejects notification payloads of 8000 bytes or more
+const MaxPayloadSize = 7999
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+type Handler func(payload []byte)
+
+// PubSub delivers payloads to every instance connected to the same database
+// through LISTEN/NOTIFY.
+type PubSub struct {
+	pool     *pgxpool.Pool
+	channel  string
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewPubSub(pool *pgxpool.Pool, channel string) *PubSub {
+	return &PubSub{
+		pool:    pool,
+		channel: channel,
+	}
+}
+
+func (p *PubSub) Publish(ctx context.Context, payload []byte) error {
+	if len(payload) > MaxPayloadSize {
+		return &PayloadTooLargeError{}
+	}
+
+	_, err := p.pool.Exec(ctx, notifyQuery, p.channel, string(payload))
+	if err != nil {
+		slog.Error("[PubSub-Publish]", "Error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Subscribe registers a handler that is called with every payload received
+// by Listen. Handlers are called sequentially and should return quickly.
+func (p *PubSub) Subscribe(handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handlers = append(p.handlers, handler)
+}
+
+// Listen receives notifications on a dedicated connection until the context
+// is cancelled, reconnecting whenever the connection is lost. Notifications
+// sent while disconnected are not received.
+func (p *PubSub) Listen(ctx context.Context) {
+	delay := minReconnectDelay
+
+	for {
+		listening, err := p.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Only back off further while the database stays unreachable
+		if listening {
+			delay = minReconnectDelay
+		}
+
+		slog.Error("[PubSub-Listen]", "Error", err, "RetryIn", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+func (p *PubSub) listen(ctx context.Context) (bool, error) {
+	// The connection is kept outside of the pool as it is blocked waiting
+	// for notifications for as long as the instance runs.
+	conn, err := pgx.ConnectConfig(ctx, p.pool.Config().ConnConfig.Copy())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{p.channel}.Sanitize())
+	if err != nil {
+		return false, err
+	}
+
+	slog.Info("[PubSub-listen]", "Channel", p.channel, "Status", "listening")
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		p.dispatch([]byte(notification.Payload))
+	}
+}
+
+func (p *PubSub) dispatch(payload []byte) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, handler := range p.handlers {
+		handler(payload)
+	}
+}
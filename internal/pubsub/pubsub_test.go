@@ -0,0 +1,107 @@
+package pubsub_test
+
+import (
+	"context"
+	"fmt"
+	"go_chat/internal/pubsub"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+type TestDbContainer struct {
+	Container testcontainers.Container
+	Pool      *pgxpool.Pool
+}
+
+func SetupTestDB(ctx context.Context) (*TestDbContainer, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        "postgres",
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_USER":     "testuser",
+			"POSTGRES_PASSWORD": "testpass",
+			"POSTGRES_DB":       "testdb",
+		},
+		WaitingFor: wait.ForListeningPort("5432/tcp"),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := container.MappedPort(ctx, "5432")
+	if err != nil {
+		return nil, err
+	}
+
+	dsn := fmt.Sprintf("postgres://testuser:testpass@%s:%s/testdb?sslmode=disable", host, port.Port())
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TestDbContainer{
+		Container: container,
+		Pool:      pool,
+	}, nil
+}
+
+func (c *TestDbContainer) Terminate(ctx context.Context) error {
+	c.Pool.Close()
+	return c.Container.Terminate(ctx)
+}
+
+func TestPubSub_PublishAndListen(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	// Two instances sharing the same database
+	first := pubsub.NewPubSub(testDb.Pool, "test_channel")
+	second := pubsub.NewPubSub(testDb.Pool, "test_channel")
+
+	var firstReceived, secondReceived atomic.Int32
+	first.Subscribe(func(payload []byte) { firstReceived.Add(1) })
+	second.Subscribe(func(payload []byte) { secondReceived.Add(1) })
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go first.Listen(listenCtx)
+	go second.Listen(listenCtx)
+
+	t.Run("deliver payload to every listener", func(t *testing.T) {
+		// LISTEN is issued asynchronously, keep publishing until both
+		// instances are listening
+		require.Eventually(t, func() bool {
+			err := first.Publish(ctx, []byte("hello"))
+			require.NoError(t, err)
+			return firstReceived.Load() > 0 && secondReceived.Load() > 0
+		}, 10*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("reject payload that does not fit in a notification", func(t *testing.T) {
+		payload := []byte(strings.Repeat("a", pubsub.MaxPayloadSize+1))
+
+		err := first.Publish(ctx, payload)
+		require.Error(t, err)
+		require.ErrorIs(t, err, &pubsub.PayloadTooLargeError{})
+	})
+}
@@ -23,3 +23,21 @@ type NoFieldToUpdateError struct{}
 func (e *NoFieldToUpdateError) Error() string {
 	return "No field to update"
 }
+
+type InvalidCredentialsError struct{}
+
+func (e *InvalidCredentialsError) Error() string {
+	return "Invalid username or password"
+}
+
+type ActionIsForbiddenError struct{}
+
+func (e *ActionIsForbiddenError) Error() string {
+	return "You are not allowed to perform this action"
+}
+
+type SessionDoesNotExistError struct{}
+
+func (e *SessionDoesNotExistError) Error() string {
+	return "Session does not exist"
+}
@@ -1,6 +1,9 @@
 package user
 
 import (
+	"errors"
+	"fmt"
+	"go_chat/internal/auth"
 	"log/slog"
 	"net/http"
 	"net/mail"
@@ -34,6 +37,14 @@ func (h *UserHandler) CreateUserHandler(ctx *gin.Context) {
 		return
 	}
 
+	if len(req.Password) < minPasswordLength || len(req.Password) > maxPasswordLength {
+		slog.Error("[UserHandler-CreateUserHandler]", "Error", "Password length is out of bounds")
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Password must be between %d and %d bytes long", minPasswordLength, maxPasswordLength),
+		})
+		return
+	}
+
 	user, err := h.service.CreateUser(ctx.Request.Context(), req)
 
 	if err != nil {
@@ -77,10 +88,17 @@ func (h *UserHandler) DeleteUserHandler(ctx *gin.Context) {
 	}
 
 	// For now, the returned user is discarded
-	_, err := h.service.DeleteUserById(ctx.Request.Context(), req.UserId)
+	_, err := h.service.DeleteUserById(ctx.Request.Context(), auth.UserId(ctx), req.UserId)
 
 	if err != nil {
 		slog.Error("[UserHandler-DeleteUserHandler]", "Error", err)
+
+		var forbiddenErr *ActionIsForbiddenError
+		if errors.As(err, &forbiddenErr) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": forbiddenErr.Error()})
+			return
+		}
+
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
@@ -104,13 +122,179 @@ func (h *UserHandler) UpdateUserHandler(ctx *gin.Context) {
 		return
 	}
 
-	user, err := h.service.UpdateUserById(ctx.Request.Context(), req.UserId, req.NewUsername, req.NewEmail)
+	user, err := h.service.UpdateUserById(ctx.Request.Context(), auth.UserId(ctx), req.UserId, req.NewUsername, req.NewEmail)
 
 	if err != nil {
 		slog.Error("[UserHandler-UpdateUserHandler]", "Error", err)
+
+		var forbiddenErr *ActionIsForbiddenError
+		if errors.As(err, &forbiddenErr) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": forbiddenErr.Error()})
+			return
+		}
+
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, user)
 }
+
+// POST /auth/login
+func (h *UserHandler) LoginHandler(ctx *gin.Context) {
+	var req LoginRequest
+
+	if err := ctx.BindJSON(&req); err != nil {
+		slog.Error("[UserHandler-LoginHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	res, err := h.service.Login(ctx.Request.Context(), req, ctx.Request.UserAgent(), ctx.ClientIP())
+
+	if err != nil {
+		slog.Error("[UserHandler-LoginHandler]", "Error", err)
+
+		var credentialsErr *InvalidCredentialsError
+		if errors.As(err, &credentialsErr) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": credentialsErr.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+// POST /auth/refresh
+func (h *UserHandler) RefreshHandler(ctx *gin.Context) {
+	var req RefreshRequest
+
+	if err := ctx.BindJSON(&req); err != nil {
+		slog.Error("[UserHandler-RefreshHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	res, err := h.service.Refresh(ctx.Request.Context(), req)
+
+	if err != nil {
+		slog.Error("[UserHandler-RefreshHandler]", "Error", err)
+
+		var tokenErr *auth.InvalidTokenError
+		if errors.As(err, &tokenErr) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": tokenErr.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+// POST /auth/logout
+func (h *UserHandler) LogoutHandler(ctx *gin.Context) {
+	err := h.service.Logout(ctx.Request.Context(), auth.UserId(ctx), auth.SessionId(ctx))
+
+	if err != nil {
+		slog.Error("[UserHandler-LogoutHandler]", "Error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// GET /users/:user_id/sessions
+func (h *UserHandler) GetSessionsHandler(ctx *gin.Context) {
+	var req GetSessionsRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[UserHandler-GetSessionsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	sessions, err := h.service.GetSessions(ctx.Request.Context(), auth.UserId(ctx), req.UserId, auth.SessionId(ctx))
+
+	if err != nil {
+		slog.Error("[UserHandler-GetSessionsHandler]", "Error", err)
+
+		var forbiddenErr *ActionIsForbiddenError
+		if errors.As(err, &forbiddenErr) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": forbiddenErr.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+// DELETE /users/:user_id/sessions
+func (h *UserHandler) RevokeSessionsHandler(ctx *gin.Context) {
+	var req RevokeSessionsRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[UserHandler-RevokeSessionsHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	err := h.service.RevokeSessions(ctx.Request.Context(), auth.UserId(ctx), req.UserId)
+
+	if err != nil {
+		slog.Error("[UserHandler-RevokeSessionsHandler]", "Error", err)
+
+		var forbiddenErr *ActionIsForbiddenError
+		if errors.As(err, &forbiddenErr) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": forbiddenErr.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully"})
+}
+
+// DELETE /users/:user_id/sessions/:session_id
+func (h *UserHandler) RevokeSessionHandler(ctx *gin.Context) {
+	var req RevokeSessionRequest
+
+	if err := ctx.BindUri(&req); err != nil {
+		slog.Error("[UserHandler-RevokeSessionHandler]", "Error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		return
+	}
+
+	session, err := h.service.RevokeSession(ctx.Request.Context(), auth.UserId(ctx), req.UserId, req.SessionId)
+
+	if err != nil {
+		slog.Error("[UserHandler-RevokeSessionHandler]", "Error", err)
+
+		var forbiddenErr *ActionIsForbiddenError
+		if errors.As(err, &forbiddenErr) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": forbiddenErr.Error()})
+			return
+		}
+
+		var notExistErr *SessionDoesNotExistError
+		if errors.As(err, &notExistErr) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": notExistErr.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, session)
+}
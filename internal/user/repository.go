@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -28,6 +29,20 @@ var (
 	updateUserByUsernameQuery string
 	//go:embed sql/delete_user_by_username.sql
 	deleteUserByUsernameQuery string
+	//go:embed sql/create_session.sql
+	createSessionQuery string
+	//go:embed sql/rotate_session.sql
+	rotateSessionQuery string
+	//go:embed sql/revoke_session_by_previous_token.sql
+	revokeSessionByPreviousTokenQuery string
+	//go:embed sql/revoke_session_by_id.sql
+	revokeSessionByIdQuery string
+	//go:embed sql/revoke_sessions_by_user_id.sql
+	revokeSessionsByUserIdQuery string
+	//go:embed sql/get_active_sessions_by_user_id.sql
+	getActiveSessionsByUserIdQuery string
+	//go:embed sql/is_session_active.sql
+	isSessionActiveQuery string
 )
 
 type UserRepository struct {
@@ -38,13 +53,14 @@ func NewUserRepository(pool *pgxpool.Pool) *UserRepository {
 	return &UserRepository{pool: pool}
 }
 
-func (r *UserRepository) CreateUser(ctx context.Context, username string, email string) (User, error) {
+func (r *UserRepository) CreateUser(ctx context.Context, username string, email string, passwordHash string) (User, error) {
 	var user User
-	err := r.pool.QueryRow(ctx, createUserQuery, username, email).
+	err := r.pool.QueryRow(ctx, createUserQuery, username, email, passwordHash).
 		Scan(
 			&user.Id,
 			&user.Username,
 			&user.Email,
+			&user.PasswordHash,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
@@ -75,6 +91,7 @@ func (r *UserRepository) GetUserById(ctx context.Context, userId string) (User,
 			&user.Id,
 			&user.Username,
 			&user.Email,
+			&user.PasswordHash,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
@@ -93,13 +110,28 @@ func (r *UserRepository) GetUserById(ctx context.Context, userId string) (User,
 	return user, nil
 }
 
+// DeleteUserById soft deletes the user and revokes all of its sessions.
 func (r *UserRepository) DeleteUserById(ctx context.Context, userId string) (User, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[UserRepository-DeleteUserById]", "Error", err)
+		return User{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
 	var user User
-	err := r.pool.QueryRow(ctx, deleteUserByIdQuery, userId).
+	err = tx.QueryRow(ctx, deleteUserByIdQuery, userId).
 		Scan(
 			&user.Id,
 			&user.Username,
 			&user.Email,
+			&user.PasswordHash,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
@@ -117,6 +149,12 @@ func (r *UserRepository) DeleteUserById(ctx context.Context, userId string) (Use
 		return User{}, errors.New("unknown error when trying to DELETE user by ID")
 	}
 
+	_, err = tx.Exec(ctx, revokeSessionsByUserIdQuery, user.Id)
+	if err != nil {
+		slog.Error("[UserRepository-DeleteUserById]", "Error", err)
+		return User{}, err
+	}
+
 	return user, nil
 }
 
@@ -151,6 +189,7 @@ func (r *UserRepository) UpdateUserById(ctx context.Context, userId *string, new
 			&user.Id,
 			&user.Username,
 			&user.Email,
+			&user.PasswordHash,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
@@ -186,6 +225,7 @@ func (r *UserRepository) GetUserByUsername(ctx context.Context, username string)
 			&user.Id,
 			&user.Username,
 			&user.Email,
+			&user.PasswordHash,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
@@ -204,13 +244,28 @@ func (r *UserRepository) GetUserByUsername(ctx context.Context, username string)
 	return user, nil
 }
 
+// DeleteUserByUsername soft deletes the user and revokes all of its sessions.
 func (r *UserRepository) DeleteUserByUsername(ctx context.Context, username string) (User, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		slog.Error("[UserRepository-DeleteUserByUsername]", "Error", err)
+		return User{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
 	var user User
-	err := r.pool.QueryRow(ctx, deleteUserByUsernameQuery, username).
+	err = tx.QueryRow(ctx, deleteUserByUsernameQuery, username).
 		Scan(
 			&user.Id,
 			&user.Username,
 			&user.Email,
+			&user.PasswordHash,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
@@ -227,6 +282,12 @@ func (r *UserRepository) DeleteUserByUsername(ctx context.Context, username stri
 		return User{}, errors.New("unknown error when trying to DELETE user by username")
 	}
 
+	_, err = tx.Exec(ctx, revokeSessionsByUserIdQuery, user.Id)
+	if err != nil {
+		slog.Error("[UserRepository-DeleteUserByUsername]", "Error", err)
+		return User{}, err
+	}
+
 	return user, nil
 }
 
@@ -261,6 +322,7 @@ func (r *UserRepository) UpdateUserByUsername(ctx context.Context, userId *strin
 			&user.Id,
 			&user.Username,
 			&user.Email,
+			&user.PasswordHash,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
@@ -287,3 +349,127 @@ func (r *UserRepository) UpdateUserByUsername(ctx context.Context, userId *strin
 
 	return user, nil
 }
+
+func scanSession(row pgx.Row, session *Session) error {
+	return row.Scan(
+		&session.Id,
+		&session.UserId,
+		&session.UserAgent,
+		&session.IpAddress,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+}
+
+func (r *UserRepository) CreateSession(ctx context.Context, userId string, refreshTokenHash string, userAgent string, ipAddress string, ttl time.Duration) (Session, error) {
+	var session Session
+	err := scanSession(r.pool.QueryRow(ctx, createSessionQuery, userId, refreshTokenHash, userAgent, ipAddress, ttl), &session)
+
+	if err != nil {
+		slog.Error("[UserRepository-CreateSession]", "Error", err)
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+// RotateSession replaces the refresh token of the active session it belongs
+// to and extends the session by ttl.
+func (r *UserRepository) RotateSession(ctx context.Context, refreshTokenHash string, newRefreshTokenHash string, ttl time.Duration) (Session, error) {
+	var session Session
+	err := scanSession(r.pool.QueryRow(ctx, rotateSessionQuery, refreshTokenHash, newRefreshTokenHash, ttl), &session)
+
+	if err != nil {
+		slog.Error("[UserRepository-RotateSession]", "Error", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Session{}, &SessionDoesNotExistError{}
+		}
+
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+// RevokeSessionByPreviousToken revokes the session a refresh token was
+// rotated out of. Such a token being used again means it was leaked.
+func (r *UserRepository) RevokeSessionByPreviousToken(ctx context.Context, refreshTokenHash string) (Session, error) {
+	var session Session
+	err := scanSession(r.pool.QueryRow(ctx, revokeSessionByPreviousTokenQuery, refreshTokenHash), &session)
+
+	if err != nil {
+		slog.Error("[UserRepository-RevokeSessionByPreviousToken]", "Error", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Session{}, &SessionDoesNotExistError{}
+		}
+
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+func (r *UserRepository) RevokeSessionById(ctx context.Context, userId string, sessionId string) (Session, error) {
+	var session Session
+	err := scanSession(r.pool.QueryRow(ctx, revokeSessionByIdQuery, sessionId, userId), &session)
+
+	if err != nil {
+		slog.Error("[UserRepository-RevokeSessionById]", "Error", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Session{}, &SessionDoesNotExistError{}
+		}
+
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+func (r *UserRepository) RevokeSessionsByUserId(ctx context.Context, userId string) error {
+	_, err := r.pool.Exec(ctx, revokeSessionsByUserIdQuery, userId)
+	if err != nil {
+		slog.Error("[UserRepository-RevokeSessionsByUserId]", "Error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *UserRepository) GetActiveSessionsByUserId(ctx context.Context, userId string) ([]Session, error) {
+	rows, err := r.pool.Query(ctx, getActiveSessionsByUserIdQuery, userId)
+	if err != nil {
+		slog.Error("[UserRepository-GetActiveSessionsByUserId]", "Error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := scanSession(rows, &session); err != nil {
+			slog.Error("[UserRepository-GetActiveSessionsByUserId]", "Error", err)
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("[UserRepository-GetActiveSessionsByUserId]", "Error", err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *UserRepository) IsSessionActive(ctx context.Context, userId string, sessionId string) (bool, error) {
+	var active bool
+	err := r.pool.QueryRow(ctx, isSessionActiveQuery, sessionId, userId).Scan(&active)
+	if err != nil {
+		slog.Error("[UserRepository-IsSessionActive]", "Error", err)
+		return false, err
+	}
+
+	return active, nil
+}
@@ -7,6 +7,7 @@ import (
 	"go_chat/internal/user"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,6 +16,9 @@ import (
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
+// Repositories store whatever hash they are given
+const testPasswordHash = "test_password_hash"
+
 type TestDbContainer struct {
 	Container testcontainers.Container
 	Pool      *pgxpool.Pool
@@ -91,7 +95,7 @@ func TestRepository_CreateUser(t *testing.T) {
 		username := "test_user"
 		email := "test@example.org"
 
-		testUser, err := repo.CreateUser(ctx, username, email)
+		testUser, err := repo.CreateUser(ctx, username, email, testPasswordHash)
 		require.NoError(t, err)
 		require.Equal(t, testUser.Username, username)
 		require.Equal(t, testUser.Email, email)
@@ -103,7 +107,7 @@ func TestRepository_CreateUser(t *testing.T) {
 	// 	username := ""
 	// 	email := "test2@example.org"
 
-	// 	_, err = repo.CreateUser(ctx, username, email)
+	// 	_, err = repo.CreateUser(ctx, username, email, testPasswordHash)
 	// 	require.Error(t, err)
 	// 	require.ErrorIs(t, err, &user.UsernameIsEmptyError{})
 	// })
@@ -114,7 +118,7 @@ func TestRepository_CreateUser(t *testing.T) {
 	// 	username := "test_user3"
 	// 	email := "test"
 
-	// 	_, err = repo.CreateUser(ctx, username, email)
+	// 	_, err = repo.CreateUser(ctx, username, email, testPasswordHash)
 	// 	require.Error(t, err)
 	// })
 
@@ -122,7 +126,7 @@ func TestRepository_CreateUser(t *testing.T) {
 		username := "test_user"
 		email := "test4@example.org"
 
-		_, err = repo.CreateUser(ctx, username, email)
+		_, err = repo.CreateUser(ctx, username, email, testPasswordHash)
 		require.Error(t, err)
 		require.ErrorIs(t, err, &user.UsernameIsTakenError{})
 	})
@@ -131,7 +135,7 @@ func TestRepository_CreateUser(t *testing.T) {
 		username := "test_user5"
 		email := "test@example.org"
 
-		_, err = repo.CreateUser(ctx, username, email)
+		_, err = repo.CreateUser(ctx, username, email, testPasswordHash)
 		require.NoError(t, err)
 	})
 }
@@ -146,7 +150,7 @@ func TestRepository_GetUserById(t *testing.T) {
 	username := "test_user"
 	email := "test@example.org"
 
-	testUser, err := repo.CreateUser(ctx, username, email)
+	testUser, err := repo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	t.Run("get existing user by id successfully", func(t *testing.T) {
@@ -174,7 +178,7 @@ func TestRepository_DeleteUserById(t *testing.T) {
 	username := "test_user"
 	email := "test@example.org"
 
-	testUser, err := repo.CreateUser(ctx, username, email)
+	testUser, err := repo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	t.Run("delete user by id successfully", func(t *testing.T) {
@@ -212,7 +216,7 @@ func TestRepository_UpdateUserById(t *testing.T) {
 	username := "test_user"
 	email := "test@example.org"
 
-	testUser, err := repo.CreateUser(ctx, username, email)
+	testUser, err := repo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	t.Run("update username by user id successfully", func(t *testing.T) {
@@ -257,7 +261,7 @@ func TestRepository_UpdateUserById(t *testing.T) {
 
 	t.Run("try to update username with existing username by id", func(t *testing.T) {
 		otherUsername := "test_user2"
-		testUser, err := repo.CreateUser(ctx, otherUsername, email)
+		testUser, err := repo.CreateUser(ctx, otherUsername, email, testPasswordHash)
 		require.NoError(t, err)
 
 		testUser, err = repo.UpdateUserById(ctx, &testUser.Id, &username, nil)
@@ -277,7 +281,7 @@ func TestRepository_GetUserByUsername(t *testing.T) {
 	username := "test_user"
 	email := "test@example.org"
 
-	testUser, err := repo.CreateUser(ctx, username, email)
+	testUser, err := repo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	t.Run("get user by username successfully", func(t *testing.T) {
@@ -285,6 +289,7 @@ func TestRepository_GetUserByUsername(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, testUser.Username, testUserFromDB.Username)
 		require.Equal(t, testUser.Email, testUserFromDB.Email)
+		require.Equal(t, testPasswordHash, testUserFromDB.PasswordHash)
 	})
 
 	t.Run("try to get non-existing user by username", func(t *testing.T) {
@@ -306,7 +311,7 @@ func TestRepository_DeleteUserByUsername(t *testing.T) {
 	username := "test_user"
 	email := "test@example.org"
 
-	testUser, err := repo.CreateUser(ctx, username, email)
+	testUser, err := repo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	t.Run("delete user by username successfully", func(t *testing.T) {
@@ -344,7 +349,7 @@ func TestRepository_UpdateUserByUsername(t *testing.T) {
 	username := "test_user"
 	email := "test@example.org"
 
-	testUser, err := repo.CreateUser(ctx, username, email)
+	testUser, err := repo.CreateUser(ctx, username, email, testPasswordHash)
 	require.NoError(t, err)
 
 	t.Run("update username by username", func(t *testing.T) {
@@ -386,7 +391,7 @@ func TestRepository_UpdateUserByUsername(t *testing.T) {
 
 	t.Run("try to update username with existing username by username", func(t *testing.T) {
 		otherUsername := "test_user2"
-		testUser, err := repo.CreateUser(ctx, otherUsername, email)
+		testUser, err := repo.CreateUser(ctx, otherUsername, email, testPasswordHash)
 		require.NoError(t, err)
 
 		testUser, err = repo.UpdateUserByUsername(ctx, &testUser.Username, &username, nil)
@@ -394,3 +399,76 @@ func TestRepository_UpdateUserByUsername(t *testing.T) {
 		require.ErrorIs(t, err, &user.UsernameIsTakenError{})
 	})
 }
+
+func TestRepository_Sessions(t *testing.T) {
+	ctx := context.Background()
+	testDb, err := SetupTestDB(ctx)
+	require.NoError(t, err)
+	defer testDb.Terminate(ctx)
+
+	repo := user.NewUserRepository(testDb.Pool)
+
+	username := "test_user"
+	email := "test@example.org"
+
+	testUser, err := repo.CreateUser(ctx, username, email, testPasswordHash)
+	require.NoError(t, err)
+
+	session, err := repo.CreateSession(ctx, testUser.Id, "first_hash", "test-agent", "127.0.0.1", time.Hour)
+	require.NoError(t, err)
+
+	t.Run("created session is active", func(t *testing.T) {
+		active, err := repo.IsSessionActive(ctx, testUser.Id, session.Id)
+		require.NoError(t, err)
+		require.True(t, active)
+
+		sessions, err := repo.GetActiveSessionsByUserId(ctx, testUser.Id)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		require.Equal(t, sessions[0].UserAgent, "test-agent")
+	})
+
+	t.Run("rotate session", func(t *testing.T) {
+		rotated, err := repo.RotateSession(ctx, "first_hash", "second_hash", time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, rotated.Id, session.Id)
+
+		_, err = repo.RotateSession(ctx, "first_hash", "third_hash", time.Hour)
+		require.Error(t, err)
+		require.ErrorIs(t, err, &user.SessionDoesNotExistError{})
+	})
+
+	t.Run("revoke session by reused token", func(t *testing.T) {
+		revoked, err := repo.RevokeSessionByPreviousToken(ctx, "first_hash")
+		require.NoError(t, err)
+		require.Equal(t, revoked.Id, session.Id)
+
+		active, err := repo.IsSessionActive(ctx, testUser.Id, session.Id)
+		require.NoError(t, err)
+		require.False(t, active)
+	})
+
+	t.Run("revoke session by id", func(t *testing.T) {
+		other, err := repo.CreateSession(ctx, testUser.Id, "other_hash", "", "", time.Hour)
+		require.NoError(t, err)
+
+		_, err = repo.RevokeSessionById(ctx, uuid.New().String(), other.Id)
+		require.Error(t, err)
+		require.ErrorIs(t, err, &user.SessionDoesNotExistError{})
+
+		_, err = repo.RevokeSessionById(ctx, testUser.Id, other.Id)
+		require.NoError(t, err)
+	})
+
+	t.Run("delete user revokes its sessions", func(t *testing.T) {
+		remaining, err := repo.CreateSession(ctx, testUser.Id, "remaining_hash", "", "", time.Hour)
+		require.NoError(t, err)
+
+		_, err = repo.DeleteUserById(ctx, testUser.Id)
+		require.NoError(t, err)
+
+		active, err := repo.IsSessionActive(ctx, testUser.Id, remaining.Id)
+		require.NoError(t, err)
+		require.False(t, active)
+	})
+}
@@ -3,6 +3,7 @@ package user
 type CreateUserRequest struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Password string `json:"password"`
 }
 
 type GetUserRequest struct {
@@ -18,3 +19,25 @@ type UpdateUserRequest struct {
 	NewUsername *string `json:"username,omitempty"`
 	NewEmail    *string `json:"email,omitempty"`
 }
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type GetSessionsRequest struct {
+	UserId string `uri:"user_id"`
+}
+
+type RevokeSessionsRequest struct {
+	UserId string `uri:"user_id"`
+}
+
+type RevokeSessionRequest struct {
+	UserId    string `uri:"user_id"`
+	SessionId string `uri:"session_id"`
+}
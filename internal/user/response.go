@@ -0,0 +1,11 @@
+package user
+
+import "time"
+
+type TokenResponse struct {
+	User         User      `json:"user"`
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+}
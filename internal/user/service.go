@@ -2,21 +2,31 @@ package user
 
 import (
 	"context"
+	"errors"
+	"go_chat/internal/auth"
 	"log/slog"
 )
 
 type UserService struct {
-	repo *UserRepository
+	repo   *UserRepository
+	tokens *auth.TokenManager
 }
 
-func NewUserService(repo *UserRepository) *UserService {
+func NewUserService(repo *UserRepository, tokens *auth.TokenManager) *UserService {
 	return &UserService{
-		repo: repo,
+		repo:   repo,
+		tokens: tokens,
 	}
 }
 
 func (s *UserService) CreateUser(ctx context.Context, req CreateUserRequest) (User, error) {
-	user, err := s.repo.CreateUser(ctx, req.Username, req.Email)
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		slog.Error("[UserService-CreateUser]", "Error", err)
+		return User{}, err
+	}
+
+	user, err := s.repo.CreateUser(ctx, req.Username, req.Email, passwordHash)
 	if err != nil {
 		slog.Error("[UserService-CreateUser]", "Error", err)
 
@@ -30,10 +40,189 @@ func (s *UserService) GetUserById(ctx context.Context, userId string) (User, err
 	return s.repo.GetUserById(ctx, userId)
 }
 
-func (s *UserService) DeleteUserById(ctx context.Context, userId string) (User, error) {
+// Users can only delete themselves
+func (s *UserService) DeleteUserById(ctx context.Context, callerId string, userId string) (User, error) {
+	if callerId != userId {
+		return User{}, &ActionIsForbiddenError{}
+	}
+
 	return s.repo.DeleteUserById(ctx, userId)
 }
 
-func (s *UserService) UpdateUserById(ctx context.Context, userId *string, newUsername *string, newEmail *string) (User, error) {
+// Users can only update themselves
+func (s *UserService) UpdateUserById(ctx context.Context, callerId string, userId *string, newUsername *string, newEmail *string) (User, error) {
+	if userId == nil || callerId != *userId {
+		return User{}, &ActionIsForbiddenError{}
+	}
+
 	return s.repo.UpdateUserById(ctx, userId, newUsername, newEmail)
 }
+
+// Login starts a new session for the user matching the credentials. Unknown
+// usernames, wrong passwords and deleted users are indistinguishable to the
+// caller.
+func (s *UserService) Login(ctx context.Context, req LoginRequest, userAgent string, ipAddress string) (TokenResponse, error) {
+	user, err := s.repo.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		var notExistErr *UserDoesNotExistError
+		if !errors.As(err, &notExistErr) {
+			slog.Error("[UserService-Login]", "Error", err)
+			return TokenResponse{}, err
+		}
+
+		checkPassword(string(dummyPasswordHash), req.Password)
+		return TokenResponse{}, &InvalidCredentialsError{}
+	}
+
+	if !checkPassword(user.PasswordHash, req.Password) || user.Deleted {
+		return TokenResponse{}, &InvalidCredentialsError{}
+	}
+
+	refreshToken, refreshTokenHash, err := auth.NewRefreshToken()
+	if err != nil {
+		slog.Error("[UserService-Login]", "Error", err)
+		return TokenResponse{}, err
+	}
+
+	session, err := s.repo.CreateSession(ctx, user.Id, refreshTokenHash, userAgent, ipAddress, s.tokens.RefreshTokenTTL())
+	if err != nil {
+		slog.Error("[UserService-Login]", "Error", err)
+		return TokenResponse{}, err
+	}
+
+	return s.issueTokens(user, session.Id, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new access token, rotating the
+// refresh token in the process. Presenting a refresh token that was already
+// rotated out revokes the whole session, as it means the token leaked.
+func (s *UserService) Refresh(ctx context.Context, req RefreshRequest) (TokenResponse, error) {
+	refreshToken, refreshTokenHash, err := auth.NewRefreshToken()
+	if err != nil {
+		slog.Error("[UserService-Refresh]", "Error", err)
+		return TokenResponse{}, err
+	}
+
+	presentedHash := auth.HashRefreshToken(req.RefreshToken)
+
+	session, err := s.repo.RotateSession(ctx, presentedHash, refreshTokenHash, s.tokens.RefreshTokenTTL())
+	if err != nil {
+		var notExistErr *SessionDoesNotExistError
+		if !errors.As(err, &notExistErr) {
+			slog.Error("[UserService-Refresh]", "Error", err)
+			return TokenResponse{}, err
+		}
+
+		if revoked, err := s.repo.RevokeSessionByPreviousToken(ctx, presentedHash); err == nil {
+			slog.Warn("[UserService-Refresh]", "Warning", "refresh token reused, session revoked", "SessionId", revoked.Id, "UserId", revoked.UserId)
+		}
+
+		return TokenResponse{}, &auth.InvalidTokenError{}
+	}
+
+	user, err := s.repo.GetUserById(ctx, session.UserId)
+	if err != nil {
+		slog.Error("[UserService-Refresh]", "Error", err)
+		return TokenResponse{}, err
+	}
+
+	if user.Deleted {
+		return TokenResponse{}, &auth.InvalidTokenError{}
+	}
+
+	return s.issueTokens(user, session.Id, refreshToken)
+}
+
+func (s *UserService) issueTokens(user User, sessionId string, refreshToken string) (TokenResponse, error) {
+	accessToken, expiresAt, err := s.tokens.IssueAccessToken(user.Id, sessionId)
+	if err != nil {
+		slog.Error("[UserService-issueTokens]", "Error", err)
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{
+		User:         user,
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// Authenticate resolves the user and the session an access token was issued
+// for. Tokens of revoked sessions and of users deleted since are rejected.
+func (s *UserService) Authenticate(ctx context.Context, accessToken string) (User, string, error) {
+	claims, err := s.tokens.ParseAccessToken(accessToken)
+	if err != nil {
+		return User{}, "", err
+	}
+
+	active, err := s.repo.IsSessionActive(ctx, claims.Subject, claims.SessionId)
+	if err != nil {
+		slog.Error("[UserService-Authenticate]", "Error", err)
+		return User{}, "", err
+	}
+
+	if !active {
+		return User{}, "", &auth.InvalidTokenError{}
+	}
+
+	user, err := s.repo.GetUserById(ctx, claims.Subject)
+	if err != nil {
+		var notExistErr *UserDoesNotExistError
+		if errors.As(err, &notExistErr) {
+			return User{}, "", &auth.InvalidTokenError{}
+		}
+
+		slog.Error("[UserService-Authenticate]", "Error", err)
+		return User{}, "", err
+	}
+
+	if user.Deleted {
+		return User{}, "", &auth.InvalidTokenError{}
+	}
+
+	return user, claims.SessionId, nil
+}
+
+// Logout revokes the session the caller is authenticated with.
+func (s *UserService) Logout(ctx context.Context, callerId string, sessionId string) error {
+	_, err := s.repo.RevokeSessionById(ctx, callerId, sessionId)
+	return err
+}
+
+// GetSessions lists the active sessions of the caller, flagging the one the
+// request was made with.
+func (s *UserService) GetSessions(ctx context.Context, callerId string, userId string, currentSessionId string) ([]Session, error) {
+	if callerId != userId {
+		return nil, &ActionIsForbiddenError{}
+	}
+
+	sessions, err := s.repo.GetActiveSessionsByUserId(ctx, userId)
+	if err != nil {
+		slog.Error("[UserService-GetSessions]", "Error", err)
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == currentSessionId
+	}
+
+	return sessions, nil
+}
+
+func (s *UserService) RevokeSession(ctx context.Context, callerId string, userId string, sessionId string) (Session, error) {
+	if callerId != userId {
+		return Session{}, &ActionIsForbiddenError{}
+	}
+
+	return s.repo.RevokeSessionById(ctx, userId, sessionId)
+}
+
+func (s *UserService) RevokeSessions(ctx context.Context, callerId string, userId string) error {
+	if callerId != userId {
+		return &ActionIsForbiddenError{}
+	}
+
+	return s.repo.RevokeSessionsByUserId(ctx, userId)
+}
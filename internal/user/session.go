@@ -0,0 +1,17 @@
+package user
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Session struct {
+	Id         string           `json:"id"`
+	UserId     string           `json:"user_id"`
+	UserAgent  string           `json:"user_agent"`
+	IpAddress  string           `json:"ip_address"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+	LastUsedAt pgtype.Timestamp `json:"last_used_at"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"`
+	RevokedAt  pgtype.Timestamp `json:"revoked_at"`
+	Current    bool             `json:"current"`
+}
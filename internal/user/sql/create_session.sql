@@ -0,0 +1,11 @@
+INSERT INTO session (user_id, refresh_token_hash, user_agent, ip_address, expires_at) 
+VALUES ($1, $2, $3, $4, NOW() + $5::interval) 
+RETURNING 
+    id,
+    user_id,
+    user_agent,
+    ip_address,
+    created_at,
+    last_used_at,
+    expires_at,
+    revoked_at
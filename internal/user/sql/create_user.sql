@@ -1,9 +1,10 @@
-INSERT INTO chat_user (username, email) 
-VALUES ($1, $2) 
+INSERT INTO chat_user (username, email, password_hash) 
+VALUES ($1, $2, $3) 
 RETURNING  
     id,
     username,
     email,
+    password_hash,
     created_at,
     updated_at,
     deleted_at,
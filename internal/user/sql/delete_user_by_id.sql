@@ -6,6 +6,7 @@ RETURNING
     id,
     username,
     email,
+    password_hash,
     created_at,
     updated_at,
     deleted_at,
@@ -0,0 +1,14 @@
+SELECT 
+    id,
+    user_id,
+    user_agent,
+    ip_address,
+    created_at,
+    last_used_at,
+    expires_at,
+    revoked_at
+FROM session 
+WHERE user_id = $1 
+    AND revoked_at IS NULL 
+    AND expires_at > NOW() 
+ORDER BY last_used_at DESC
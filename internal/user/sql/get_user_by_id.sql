@@ -1,3 +1,10 @@
-SELECT * 
+SELECT id,
+    username,
+    email,
+    password_hash,
+    created_at,
+    updated_at,
+    deleted_at,
+    deleted
 FROM chat_user 
 WHERE id = $1
@@ -0,0 +1,7 @@
+SELECT EXISTS (
+    SELECT 1 FROM session 
+    WHERE id = $1 
+        AND user_id = $2 
+        AND revoked_at IS NULL 
+        AND expires_at > NOW()
+)
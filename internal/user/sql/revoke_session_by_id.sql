@@ -0,0 +1,14 @@
+UPDATE session 
+SET revoked_at = NOW() 
+WHERE id = $1 
+    AND user_id = $2 
+    AND revoked_at IS NULL 
+RETURNING 
+    id,
+    user_id,
+    user_agent,
+    ip_address,
+    created_at,
+    last_used_at,
+    expires_at,
+    revoked_at
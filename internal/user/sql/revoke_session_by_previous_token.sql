@@ -0,0 +1,13 @@
+UPDATE session 
+SET revoked_at = NOW() 
+WHERE previous_refresh_token_hash = $1 
+    AND revoked_at IS NULL 
+RETURNING 
+    id,
+    user_id,
+    user_agent,
+    ip_address,
+    created_at,
+    last_used_at,
+    expires_at,
+    revoked_at
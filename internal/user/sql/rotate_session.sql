@@ -0,0 +1,17 @@
+UPDATE session 
+SET previous_refresh_token_hash = refresh_token_hash, 
+    refresh_token_hash = $2, 
+    last_used_at = NOW(), 
+    expires_at = NOW() + $3::interval 
+WHERE refresh_token_hash = $1 
+    AND revoked_at IS NULL 
+    AND expires_at > NOW() 
+RETURNING 
+    id,
+    user_id,
+    user_agent,
+    ip_address,
+    created_at,
+    last_used_at,
+    expires_at,
+    revoked_at